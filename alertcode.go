@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package sielink
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// Well-known values of the Alert code field. The values are part of the
+// protocol, and must not change.
+const (
+	// AlertCodeUnspecified alerts carry no code.
+	AlertCodeUnspecified uint32 = 0
+	// AlertCodeVersionMismatch alerts report that the peers share no
+	// supported protocol version.
+	AlertCodeVersionMismatch uint32 = 1
+	// AlertCodeProtocolViolation alerts report an unexpected or
+	// malformed message.
+	AlertCodeProtocolViolation uint32 = 2
+	// AlertCodeAuthRejected alerts report that the peer's credentials
+	// were not accepted.
+	AlertCodeAuthRejected uint32 = 3
+	// AlertCodeHeartbeatTimeout alerts report that the peer's heartbeats
+	// stopped arriving.
+	AlertCodeHeartbeatTimeout uint32 = 4
+	// AlertCodeUnavailable alerts report that the Link is closed or
+	// shutting down, and the peer should connect elsewhere.
+	AlertCodeUnavailable uint32 = 5
+	// AlertCodeQuotaWarning alerts warn that the peer is approaching
+	// a limit on the data it may send or receive.
+	AlertCodeQuotaWarning uint32 = 6
+	// AlertCodeDeprecated alerts warn that the peer is using a feature
+	// or protocol version which will be withdrawn.
+	AlertCodeDeprecated uint32 = 7
+	// AlertCodeBadChannel alerts report that the peer sent data on, or
+	// subscribed to, a channel it is not permitted to use.
+	AlertCodeBadChannel uint32 = 8
+	// AlertCodeClockSkew alerts warn that the recipient's clock differs
+	// from the sender's by more than the sender tolerates.
+	AlertCodeClockSkew uint32 = 9
+
+	// AlertCodePrivate is the first code available for application
+	// use. Codes below AlertCodePrivate are reserved for the protocol.
+	AlertCodePrivate uint32 = 0x10000
+)
+
+var (
+	alertCodeMutex sync.RWMutex
+	alertCodeNames = map[uint32]string{
+		AlertCodeUnspecified:       "unspecified",
+		AlertCodeVersionMismatch:   "version-mismatch",
+		AlertCodeProtocolViolation: "protocol-violation",
+		AlertCodeAuthRejected:      "auth-rejected",
+		AlertCodeHeartbeatTimeout:  "heartbeat-timeout",
+		AlertCodeUnavailable:       "unavailable",
+		AlertCodeQuotaWarning:      "quota-warning",
+		AlertCodeDeprecated:        "deprecated",
+		AlertCodeBadChannel:        "bad-channel",
+		AlertCodeClockSkew:         "clock-skew",
+	}
+)
+
+// RegisterAlertCode registers the name of an application alert code,
+// which must be at least AlertCodePrivate. It panics if the code is
+// reserved or already registered.
+func RegisterAlertCode(code uint32, name string) {
+	if code < AlertCodePrivate {
+		panic(fmt.Sprintf("sielink: alert code %d is reserved", code))
+	}
+	alertCodeMutex.Lock()
+	defer alertCodeMutex.Unlock()
+	if prev, ok := alertCodeNames[code]; ok {
+		panic(fmt.Sprintf("sielink: alert code %d already registered as %s", code, prev))
+	}
+	alertCodeNames[code] = name
+}
+
+// AlertCodeName returns the registered name of an alert code, or the
+// code in decimal if it is not registered.
+func AlertCodeName(code uint32) string {
+	alertCodeMutex.RLock()
+	defer alertCodeMutex.RUnlock()
+	if name, ok := alertCodeNames[code]; ok {
+		return name
+	}
+	return strconv.FormatUint(uint64(code), 10)
+}
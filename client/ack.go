@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package client
+
+import (
+	"context"
+	"sync"
+
+	"github.com/farsightsec/sielink"
+	"github.com/farsightsec/sielink/rawlink"
+)
+
+// An AckEvent reports a subscription acknowledgment received from a
+// server.
+type AckEvent struct {
+	// Server is the URL of the server sending the acknowledgment.
+	Server string
+	// Ack lists the subscriptions the server accepted and rejected.
+	Ack *sielink.SubscriptionAck
+}
+
+type ackWatchers struct {
+	mutex    sync.Mutex
+	watchers map[chan AckEvent]struct{}
+}
+
+func (w *ackWatchers) send(ev AckEvent) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for ch := range w.watchers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+func (w *ackWatchers) watch() (<-chan AckEvent, func()) {
+	ch := make(chan AckEvent, stateBuffer)
+	w.mutex.Lock()
+	if w.watchers == nil {
+		w.watchers = make(map[chan AckEvent]struct{})
+	}
+	w.watchers[ch] = struct{}{}
+	w.mutex.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			w.mutex.Lock()
+			delete(w.watchers, ch)
+			w.mutex.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// linkAck receives subscription acknowledgments from the Link's
+// SubscriptionAckFunc.
+func (c *basicClient) linkAck(lc *rawlink.Conn, ack *sielink.SubscriptionAck) {
+	c.acks.send(AckEvent{Server: c.conns.server(lc), Ack: ack})
+}
+
+func (c *basicClient) WatchSubscriptionAcks() (<-chan AckEvent, func()) {
+	return c.acks.watch()
+}
+
+func (c *basicClient) SubscribeAndWait(ctx context.Context, channels ...uint32) (*sielink.SubscriptionAck, error) {
+	acks, stop := c.acks.watch()
+	defer stop()
+	serial := c.subs.set(channels)
+	for {
+		select {
+		case ev := <-acks:
+			if ev.Ack.GetSerial() >= serial {
+				return ev.Ack, nil
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package client
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = 2 * time.Minute
+)
+
+// backoff computes exponentially increasing delays between connection
+// attempts, bounded by min and max. Each delay is jittered to a random
+// value between half and all of the current interval, so that sensors
+// disconnected at the same time do not reconnect in lockstep.
+type backoff struct {
+	min, max, cur time.Duration
+}
+
+func newBackoff(min, max time.Duration) *backoff {
+	if min <= 0 {
+		min = defaultMinBackoff
+	}
+	if max < min {
+		max = defaultMaxBackoff
+		if max < min {
+			max = min
+		}
+	}
+	return &backoff{min: min, max: max}
+}
+
+func (b *backoff) next() time.Duration {
+	if b.cur == 0 {
+		b.cur = b.min
+	} else if b.cur *= 2; b.cur > b.max {
+		b.cur = b.max
+	}
+	return b.cur/2 + time.Duration(rand.Int63n(int64(b.cur/2)+1))
+}
+
+func (b *backoff) reset() {
+	b.cur = 0
+}
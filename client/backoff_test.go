@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/farsightsec/sielink"
+	"github.com/farsightsec/sielink/rawlink"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff(time.Second, 10*time.Second)
+	for _, max := range []time.Duration{1, 2, 4, 8, 10, 10} {
+		max *= time.Second
+		d := b.next()
+		if d < max/2 || d > max {
+			t.Errorf("backoff %v outside [%v, %v]", d, max/2, max)
+		}
+	}
+	b.reset()
+	if d := b.next(); d > time.Second {
+		t.Errorf("backoff %v after reset exceeds minimum", d)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	if !Retryable(errors.New("connection refused")) {
+		t.Error("network error not retryable")
+	}
+	if !Retryable(&sielink.Alert{Level: sielink.AlertLevel_Warning.Enum()}) {
+		t.Error("warning alert not retryable")
+	}
+	fatal := &sielink.Alert{
+		Level:   sielink.AlertLevel_FatalError.Enum(),
+		Message: proto.String("Invalid API key"),
+	}
+	if Retryable(fatal) {
+		t.Error("fatal alert retryable")
+	}
+	if Retryable(&rawlink.RemoteAlertError{Alert: fatal}) {
+		t.Error("remote fatal alert retryable")
+	}
+	fatal.Code = proto.Uint32(sielink.AlertCodeUnavailable)
+	if !Retryable(&rawlink.RemoteAlertError{Alert: fatal}) {
+		t.Error("unavailable server not retryable")
+	}
+	if Retryable(&rawlink.VersionError{Remote: []uint32{0}}) {
+		t.Error("version mismatch retryable")
+	}
+	for _, err := range []error{rawlink.ErrLinkClosed, rawlink.ErrLinkShutdown,
+		rawlink.ErrLinkFinished} {
+		if Retryable(err) {
+			t.Errorf("%v retryable", err)
+		}
+	}
+}
@@ -9,14 +9,18 @@
 package client
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
+	"log/slog"
 	"net"
+	"sync"
 	"time"
 
 	"golang.org/x/net/websocket"
 
 	"github.com/farsightsec/sielink"
+	"github.com/farsightsec/sielink/metrics"
 	"github.com/farsightsec/sielink/rawlink"
 )
 
@@ -36,13 +40,114 @@ type Client interface {
 	// has a SRV record for http / tcp, DialAndHandleSRV will connect
 	// using the SRV record hosts and ports. Otherwise, DialAndHandleSRV
 	// will behave exactly like DialAndHandle.
-	//  DialAndHandleSRV(uri string) error
+	//
+	// SRV targets are tried in order of priority, and randomly by
+	// weight within a priority, failing over to the next target if
+	// a connection cannot be established. The SRV records are cached,
+	// and looked up again after Config.SRVRefresh has elapsed or all
+	// targets have failed.
+	DialAndHandleSRV(uri string) error
 
 	// Subscribe requests data available on the supplied channels
-	// from the servers.
+	// from the servers, replacing any previous subscriptions.
 	Subscribe(channels ...uint32)
 
+	// AddSubscription requests data available on the supplied
+	// channels from the servers, in addition to any channels
+	// previously subscribed.
+	AddSubscription(channels ...uint32)
+
+	// SubscribeRange requests data on all channels from first to
+	// last, inclusive, in addition to any channels previously
+	// subscribed.
+	SubscribeRange(first, last uint32)
+
+	// SubscribeAll requests data on all channels.
+	SubscribeAll()
+
+	// SubscribeSite requests data on the supplied channels which
+	// was submitted to the given source site, in addition to any
+	// channels previously subscribed.
+	SubscribeSite(site uint32, channels ...uint32)
+
+	// Unsubscribe cancels the subscriptions to the supplied channels,
+	// for all source sites.
+	Unsubscribe(channels ...uint32)
+
+	// UnsubscribeRange cancels the subscriptions to all channels
+	// from first to last, inclusive, whether subscribed individually
+	// or as part of a range. It does not affect SubscribeAll.
+	UnsubscribeRange(first, last uint32)
+
+	// UnsubscribeAll cancels all subscriptions.
+	UnsubscribeAll()
+
+	// Subscriptions returns the effective set of subscriptions
+	// requested from the servers.
+	Subscriptions() []*sielink.Subscription
+
+	// SubscribeAndWait subscribes to the supplied channels as
+	// Subscribe does, then waits for a server to acknowledge the
+	// resulting subscription. It returns the acknowledgment listing
+	// the subscriptions the server accepted and rejected, or an error
+	// if ctx is done first. Servers which do not support acknowledgments
+	// never send one.
+	SubscribeAndWait(ctx context.Context, channels ...uint32) (*sielink.SubscriptionAck, error)
+
+	// ReceiveChannel returns a channel on which payloads received
+	// on the supplied channel number are delivered, instead of being
+	// delivered through Receive. Each such stream has its own buffer
+	// of Config.StreamBuffer payloads, so that separate consumers do
+	// not wait on one another unless a stream's buffer is full.
+	ReceiveChannel(channel uint32) <-chan *sielink.Payload
+
+	// ReceiveType returns a stream of payloads of the supplied type,
+	// as ReceiveChannel does for channel numbers. Payloads matching
+	// a ReceiveChannel stream are delivered there instead.
+	ReceiveType(ptype sielink.PayloadType) <-chan *sielink.Payload
+
+	// ReceiveChannelType returns a stream of payloads of the supplied
+	// type on the supplied channel, which takes precedence over the
+	// ReceiveChannel and ReceiveType streams.
+	ReceiveChannelType(channel uint32, ptype sielink.PayloadType) <-chan *sielink.Payload
+
+	// WatchSubscriptionAcks returns a channel delivering an AckEvent
+	// for each subscription acknowledgment received, and a function
+	// which ends delivery and closes the channel.
+	WatchSubscriptionAcks() (<-chan AckEvent, func())
+
+	// Ready returns a channel which is closed once any connection has
+	// completed its handshake with a server. When no connections remain
+	// established, subsequent calls return a new channel which is closed
+	// on the next successful handshake.
 	Ready() <-chan struct{}
+
+	// State returns the aggregate state of the client's connections:
+	// the most advanced state of any connection, or StateClosed if
+	// there are none.
+	State() rawlink.ConnState
+
+	// WatchState returns a channel delivering a StateEvent for each
+	// connection state change, and a function which ends delivery
+	// and closes the channel. Events are discarded if the channel's
+	// buffer is full.
+	WatchState() (<-chan StateEvent, func())
+
+	// Connections returns a snapshot of the client's established and
+	// handshaking connections.
+	Connections() []rawlink.ConnInfo
+
+	// Metrics returns the registry of the client's metrics, which
+	// serves them in the Prometheus text format as an http.Handler.
+	Metrics() *metrics.Registry
+
+	// Run maintains connections to the servers listed in the Config,
+	// reconnecting with backoff as connections end. It returns nil
+	// once Stop is called, or the first error which is not Retryable.
+	Run() error
+
+	// Stop ends Run and closes the Link.
+	Stop()
 }
 
 // Config contains the configuration for a sieproto Client link.
@@ -51,52 +156,134 @@ type Config struct {
 	URL       string
 	APIKey    string
 	TLSConfig *tls.Config
+
+	// Servers lists the URLs of the servers to which Run connects,
+	// using DialAndHandleSRV.
+	Servers []string
+	// Connections is the number of concurrent connections maintained
+	// by Run. If zero, Run maintains a single connection.
+	Connections int
+	// MinBackoff and MaxBackoff bound the delay between successive
+	// connection attempts made by Run. They default to one second
+	// and two minutes respectively.
+	MinBackoff, MaxBackoff time.Duration
+
+	// Resolver is used to look up SRV records. If nil,
+	// net.DefaultResolver is used.
+	Resolver Resolver
+	// SRVRefresh is the interval after which DialAndHandleSRV
+	// looks up SRV records again. It defaults to five minutes.
+	SRVRefresh time.Duration
+
+	// DialTimeout bounds the time taken by each connection attempt,
+	// including the TLS and websocket handshakes. It defaults to ten
+	// seconds.
+	DialTimeout time.Duration
+	// DialStagger is the delay between starting concurrent connection
+	// attempts to successive addresses of a server. It defaults to
+	// 250 milliseconds.
+	DialStagger time.Duration
+
+	// ProxyURL is the URL of a proxy through which connections are
+	// made. The "http" and "https" schemes select an HTTP proxy using
+	// the CONNECT method, and "socks5" selects a SOCKS5 proxy. Proxy
+	// credentials may be supplied in the URL user information.
+	ProxyURL string
+	// ProxyFromEnvironment selects a proxy from the HTTPS_PROXY,
+	// HTTP_PROXY, and NO_PROXY environment variables if ProxyURL
+	// is empty.
+	ProxyFromEnvironment bool
+
+	// StreamBuffer is the number of payloads buffered by each stream
+	// returned from ReceiveChannel, ReceiveType and ReceiveChannelType.
+	// It defaults to 100.
+	StreamBuffer int
+
+	// Logger, if set, receives structured log records of the client's
+	// connection attempts and connection events.
+	Logger *slog.Logger
 }
 
 type basicClient struct {
 	*rawlink.Link
 	Config
-	ready chan struct{}
+	conns    *connTracker
+	stop     chan struct{}
+	stopOnce sync.Once
+	srv      srvCache
+	subs     subscriptions
+	acks     ackWatchers
+	streams  *streams
 }
 
-func (c *basicClient) Subscribe(channels ...uint32) {
-	c.SetSubscription([]*sielink.Subscription{
-		&sielink.Subscription{Channel: channels},
-	})
+func (c *basicClient) DialAndHandle(serverurl string) error {
+	return c.dialAndHandle(newConnection(serverurl))
 }
 
-func (c *basicClient) DialAndHandle(serverurl string) error {
-	conf, err := websocket.NewConfig(serverurl, c.URL)
+func (c *basicClient) dialAndHandle(tc *connection) error {
+	conf, err := c.websocketConfig(tc.server)
 	if err != nil {
 		return err
 	}
+
+	c.conns.dialing(tc)
+	conn, err := c.dialer().dialConfig(conf)
+	return c.handle(tc, conn, err)
+}
+
+func (c *basicClient) websocketConfig(serverurl string) (*websocket.Config, error) {
+	conf, err := websocket.NewConfig(serverurl, c.URL)
+	if err != nil {
+		return nil, err
+	}
 	conf.TlsConfig = c.TLSConfig
 	if c.APIKey != "" {
 		conf.Header.Set("X-API-Key", c.APIKey)
 	}
+	return conf, nil
+}
 
-	conn, err := dialConfig(conf)
+// handle runs the Link protocol on a connection dialed for tc, or records
+// the failure to dial it.
+func (c *basicClient) handle(tc *connection, conn *websocket.Conn, err error) error {
 	if err != nil {
+		c.Link.Logger.Warn("dial failed", "server", tc.server, "err", err)
+		c.conns.set(tc, rawlink.StateClosed, err)
 		return err
 	}
-
-	close(c.ready)
+	c.conns.attach(tc, conn)
 	return c.HandleConnection(conn)
 }
 
-func (c *basicClient) Ready() <-chan struct{} {
-	return c.ready
-}
-
 // NewClient creates a Link appropriate for use as a client for uploading
 // and subscribing to data from a collection of routers.
 func NewClient(conf *Config) Client {
 	rl := rawlink.NewLink()
 	rl.Heartbeat = conf.Heartbeat
-	return &basicClient{rl, *conf, make(chan struct{})}
+	if conf.Logger != nil {
+		rl.Logger = conf.Logger
+	}
+	c := &basicClient{
+		Link:    rl,
+		Config:  *conf,
+		conns:   newConnTracker(),
+		streams: newStreams(conf.StreamBuffer, rl.Done()),
+		stop:    make(chan struct{}),
+	}
+	rl.StateFunc = c.conns.linkState
+	rl.ShutdownFunc = c.conns.linkShutdown
+	c.subs.apply = rl.SetSubscription
+	rl.SubscriptionAckFunc = c.linkAck
+	rl.ReceiveFunc = c.streams.route
+	go c.streams.forward(rl.Receive())
+	return c
 }
 
 func getAddrs(name, service string, port uint16) (addrs []string, cn string, err error) {
+	return lookupAddrs(context.Background(), net.DefaultResolver, name, service, port)
+}
+
+func lookupAddrs(ctx context.Context, r Resolver, name, service string, port uint16) (addrs []string, cn string, err error) {
 	host, sport, err := net.SplitHostPort(name)
 	if err == nil {
 		addrs = []string{fmt.Sprintf("%s:%s", host, sport)}
@@ -105,11 +292,9 @@ func getAddrs(name, service string, port uint16) (addrs []string, cn string, err
 	}
 
 	cn = name
-	_, srvs, err := net.LookupSRV(service, "tcp", name)
+	_, srvs, err := r.LookupSRV(ctx, service, "tcp", name)
 	if err == nil {
-		for _, s := range srvs {
-			addrs = append(addrs, fmt.Sprintf("%s:%d", s.Target, s.Port))
-		}
+		addrs = srvAddrs(orderSRV(srvs))
 		return
 	}
 
@@ -122,46 +307,14 @@ func getAddrs(name, service string, port uint16) (addrs []string, cn string, err
 	return
 }
 
-func dialConfig(conf *websocket.Config) (conn *websocket.Conn, err error) {
-	port := uint16(80)
-	useTLS := false
-	service := "http"
-
-	scheme := conf.Location.Scheme
+// schemeDefaults returns the SRV service name and default port
+// for a websocket URL scheme.
+func schemeDefaults(scheme string) (service string, port uint16, err error) {
 	switch scheme {
 	case "ws":
+		return "http", 80, nil
 	case "wss":
-		port = uint16(443)
-		useTLS = true
-		service = "https"
-	default:
-		return nil, fmt.Errorf("Invalid uri scheme %s", scheme)
-	}
-
-	addrs, serverName, err := getAddrs(conf.Location.Host, service, port)
-	if err != nil {
-		return nil, err
+		return "https", 443, nil
 	}
-
-	for _, addr := range addrs {
-		var c net.Conn
-
-		if useTLS {
-			tlsc := new(tls.Config)
-			if conf.TlsConfig != nil {
-				*tlsc = *conf.TlsConfig
-			}
-			tlsc.ServerName = serverName
-			c, err = tls.Dial("tcp", addr, tlsc)
-		} else {
-			c, err = net.Dial("tcp", addr)
-		}
-		if err != nil {
-			continue
-		}
-
-		conn, err = websocket.NewClient(conf, c)
-		break
-	}
-	return
+	return "", 0, fmt.Errorf("Invalid uri scheme %s", scheme)
 }
@@ -0,0 +1,256 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	defaultDialTimeout = 10 * time.Second
+	defaultDialStagger = 250 * time.Millisecond
+)
+
+var errNoAddresses = errors.New("No addresses to dial")
+
+// A dialer establishes websocket connections, racing staggered attempts
+// to all addresses of a server (as described in RFC 8305, "Happy Eyeballs")
+// and returning the first connection to complete its handshakes.
+type dialer struct {
+	resolver Resolver
+	proxy    func(*url.URL) (*url.URL, error)
+	timeout  time.Duration
+	stagger  time.Duration
+}
+
+func (c *basicClient) dialer() *dialer {
+	d := &dialer{
+		resolver: c.resolver(),
+		proxy:    c.proxyFunc(),
+		timeout:  c.DialTimeout,
+		stagger:  c.DialStagger,
+	}
+	if d.timeout <= 0 {
+		d.timeout = defaultDialTimeout
+	}
+	if d.stagger <= 0 {
+		d.stagger = defaultDialStagger
+	}
+	return d
+}
+
+func (d *dialer) dialConfig(conf *websocket.Config) (*websocket.Conn, error) {
+	service, port, err := schemeDefaults(conf.Location.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, serverName, err := lookupAddrs(context.Background(), d.resolver, conf.Location.Host, service, port)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.dialAddrs(conf, addrs, serverName)
+}
+
+// dialAddrs establishes a websocket connection to one of addrs, listed in
+// order of preference. Each attempt is started d.stagger after the one
+// before it, or as soon as any pending attempt fails, and the first
+// attempt to succeed cancels the others.
+//
+// If the connection is made through a proxy, the proxy resolves the
+// address host names, and each host is tried once.
+func (d *dialer) dialAddrs(conf *websocket.Config, addrs []string, serverName string) (*websocket.Conn, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var proxyURL *url.URL
+	var err error
+	if d.proxy != nil {
+		if proxyURL, err = d.proxy(conf.Location); err != nil {
+			return nil, err
+		}
+	}
+	if proxyURL == nil {
+		addrs, err = d.expandAddrs(ctx, addrs)
+	}
+	if len(addrs) == 0 {
+		if err == nil {
+			err = errNoAddresses
+		}
+		return nil, err
+	}
+
+	type result struct {
+		conn *websocket.Conn
+		err  error
+	}
+	results := make(chan result, len(addrs))
+	pending, i := 0, 0
+	var stagger <-chan time.Time
+	startNext := func() {
+		addr := addrs[i]
+		i++
+		pending++
+		go func() {
+			conn, err := d.dialAttempt(ctx, conf, proxyURL, addr, serverName)
+			results <- result{conn, err}
+		}()
+		stagger = nil
+		if i < len(addrs) {
+			stagger = time.After(d.stagger)
+		}
+	}
+
+	startNext()
+	for pending > 0 {
+		select {
+		case <-stagger:
+			startNext()
+		case r := <-results:
+			pending--
+			if r.err != nil {
+				err = r.err
+				if i < len(addrs) {
+					startNext()
+				}
+				continue
+			}
+			cancel()
+			// Close any connections from attempts which completed
+			// before they could be cancelled.
+			go func(n int) {
+				for ; n > 0; n-- {
+					if r := <-results; r.conn != nil {
+						r.conn.Close()
+					}
+				}
+			}(pending)
+			return r.conn, nil
+		}
+	}
+	return nil, err
+}
+
+// dialAttempt makes a single connection attempt to addr, through proxyURL
+// if it is not nil. The attempt must complete its TCP, proxy, TLS, and
+// websocket handshakes within d.timeout.
+func (d *dialer) dialAttempt(ctx context.Context, conf *websocket.Config, proxyURL *url.URL, addr, serverName string) (*websocket.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	var c net.Conn
+	var err error
+	if proxyURL != nil {
+		c, err = dialProxy(ctx, proxyURL, addr)
+	} else {
+		var nd net.Dialer
+		c, err = nd.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Close the connection if the attempt is cancelled or times out
+	// during the TLS or websocket handshakes, which do not otherwise
+	// observe ctx. Closing the underlying connection also ends a TLS
+	// handshake layered over it.
+	raw := c
+	handshakeDone := make(chan struct{})
+	aborted := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			raw.Close()
+			aborted <- true
+		case <-handshakeDone:
+			aborted <- false
+		}
+	}()
+
+	if conf.Location.Scheme == "wss" {
+		tlsc := new(tls.Config)
+		if conf.TlsConfig != nil {
+			tlsc = conf.TlsConfig.Clone()
+		}
+		tlsc.ServerName = serverName
+		c = tls.Client(c, tlsc)
+	}
+	conn, err := websocket.NewClient(conf, c)
+
+	close(handshakeDone)
+	if <-aborted {
+		if err == nil {
+			conn.Close()
+		}
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// expandAddrs resolves the host of each "host:port" address in addrs,
+// returning the resulting IP addresses grouped by host in the original
+// order. The addresses of each host alternate between address families.
+func (d *dialer) expandAddrs(ctx context.Context, addrs []string) (res []string, err error) {
+	for _, addr := range addrs {
+		host, port, serr := net.SplitHostPort(addr)
+		if serr != nil {
+			err = serr
+			continue
+		}
+		if net.ParseIP(host) != nil {
+			res = append(res, addr)
+			continue
+		}
+		ips, lerr := d.resolver.LookupIPAddr(ctx, host)
+		if lerr != nil {
+			err = lerr
+			continue
+		}
+		for _, ip := range interleaveFamilies(ips) {
+			res = append(res, net.JoinHostPort(ip.String(), port))
+		}
+	}
+	return
+}
+
+// interleaveFamilies reorders ips to alternate between IPv6 and IPv4
+// addresses, starting with the family of the first address and otherwise
+// preserving the resolver's order.
+func interleaveFamilies(ips []net.IPAddr) []net.IPAddr {
+	var first, second []net.IPAddr
+	for _, ip := range ips {
+		if (ip.IP.To4() == nil) == (ips[0].IP.To4() == nil) {
+			first = append(first, ip)
+		} else {
+			second = append(second, ip)
+		}
+	}
+	res := make([]net.IPAddr, 0, len(ips))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			res = append(res, first[i])
+		}
+		if i < len(second) {
+			res = append(res, second[i])
+		}
+	}
+	return res
+}
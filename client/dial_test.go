@@ -0,0 +1,189 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package client
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestInterleaveFamilies(t *testing.T) {
+	ips := []net.IPAddr{
+		{IP: net.ParseIP("2001:db8::1")},
+		{IP: net.ParseIP("2001:db8::2")},
+		{IP: net.ParseIP("192.0.2.1")},
+		{IP: net.ParseIP("2001:db8::3")},
+	}
+	var res []string
+	for _, ip := range interleaveFamilies(ips) {
+		res = append(res, ip.String())
+	}
+	expected := "2001:db8::1 192.0.2.1 2001:db8::2 2001:db8::3"
+	if strings.Join(res, " ") != expected {
+		t.Errorf("interleaveFamilies returned %v, expected %s", res, expected)
+	}
+}
+
+// Dial a server whose first address accepts TCP connections but never
+// completes a handshake. Verify that the second address is connected
+// without waiting for the first to time out.
+func TestDialStagger(t *testing.T) {
+	hang, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hang.Close()
+	go func() {
+		for {
+			c, err := hang.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+
+	ts := httptest.NewServer(websocket.Handler(func(c *websocket.Conn) {
+		websocket.Message.Receive(c, new([]byte))
+	}))
+	defer ts.Close()
+
+	conf, err := websocket.NewConfig("ws://sie.test/session", "http://localhost/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &dialer{
+		resolver: &testResolver{},
+		timeout:  10 * time.Second,
+		stagger:  50 * time.Millisecond,
+	}
+
+	start := time.Now()
+	conn, err := d.dialAddrs(conf, []string{
+		hang.Addr().String(),
+		strings.TrimPrefix(ts.URL, "http://"),
+	}, "sie.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("connection took %v", elapsed)
+	}
+}
+
+// Dial a server whose first address hangs and whose second refuses
+// connections. Verify that the third address is tried as soon as the
+// second fails, while the first is still pending.
+func TestDialFailover(t *testing.T) {
+	hang, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hang.Close()
+	go func() {
+		for {
+			c, err := hang.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+	refused, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused.Close()
+
+	ts := httptest.NewServer(websocket.Handler(func(c *websocket.Conn) {
+		websocket.Message.Receive(c, new([]byte))
+	}))
+	defer ts.Close()
+
+	conf, err := websocket.NewConfig("ws://sie.test/session", "http://localhost/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &dialer{
+		resolver: &testResolver{},
+		timeout:  10 * time.Second,
+		stagger:  500 * time.Millisecond,
+	}
+
+	start := time.Now()
+	conn, err := d.dialAddrs(conf, []string{
+		hang.Addr().String(),
+		refused.Addr().String(),
+		strings.TrimPrefix(ts.URL, "http://"),
+	}, "sie.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if elapsed := time.Since(start); elapsed > 800*time.Millisecond {
+		t.Errorf("connection took %v", elapsed)
+	}
+}
+
+// As TestDialStagger, but over TLS, so that the abandoned attempt is
+// cancelled during its TLS handshake.
+func TestDialStaggerTLS(t *testing.T) {
+	hang, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hang.Close()
+	go func() {
+		for {
+			c, err := hang.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+
+	ts := httptest.NewTLSServer(websocket.Handler(func(c *websocket.Conn) {
+		websocket.Message.Receive(c, new([]byte))
+	}))
+	defer ts.Close()
+
+	conf, err := websocket.NewConfig("wss://sie.test/session", "http://localhost/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.TlsConfig = &tls.Config{InsecureSkipVerify: true}
+	d := &dialer{
+		resolver: &testResolver{},
+		timeout:  10 * time.Second,
+		stagger:  50 * time.Millisecond,
+	}
+
+	start := time.Now()
+	conn, err := d.dialAddrs(conf, []string{
+		hang.Addr().String(),
+		strings.TrimPrefix(ts.URL, "https://"),
+	}, "sie.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("connection took %v", elapsed)
+	}
+	// Allow the abandoned attempt to observe its cancellation.
+	time.Sleep(50 * time.Millisecond)
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// proxyFunc returns a function selecting the proxy, if any, through which
+// connections to a server URL are made, or nil if no proxy is configured.
+func (c *basicClient) proxyFunc() func(*url.URL) (*url.URL, error) {
+	if c.ProxyURL != "" {
+		u, err := url.Parse(c.ProxyURL)
+		return func(*url.URL) (*url.URL, error) { return u, err }
+	}
+	if !c.ProxyFromEnvironment {
+		return nil
+	}
+	envProxy := httpproxy.FromEnvironment().ProxyFunc()
+	return func(u *url.URL) (*url.URL, error) {
+		// The proxy environment variables are keyed by the http
+		// and https schemes corresponding to ws and wss.
+		ru := *u
+		switch u.Scheme {
+		case "ws":
+			ru.Scheme = "http"
+		case "wss":
+			ru.Scheme = "https"
+		}
+		return envProxy(&ru)
+	}
+}
+
+// dialProxy establishes a TCP connection to addr tunneled through the
+// proxy at proxyURL, which may be an HTTP, HTTPS, or SOCKS5 proxy. Any
+// credentials in the proxy URL are used to authenticate with the proxy.
+func dialProxy(ctx context.Context, proxyURL *url.URL, addr string) (net.Conn, error) {
+	switch proxyURL.Scheme {
+	case "http", "https":
+		return dialConnect(ctx, proxyURL, addr)
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if u := proxyURL.User; u != nil {
+			password, _ := u.Password()
+			auth = &proxy.Auth{User: u.Username(), Password: password}
+		}
+		d, err := proxy.SOCKS5("tcp", proxyAddr(proxyURL), auth, new(net.Dialer))
+		if err != nil {
+			return nil, err
+		}
+		return d.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+	}
+	return nil, fmt.Errorf("Unsupported proxy scheme %s", proxyURL.Scheme)
+}
+
+// dialConnect establishes a tunnel to addr through an HTTP proxy with
+// the CONNECT method.
+func dialConnect(ctx context.Context, proxyURL *url.URL, addr string) (_ net.Conn, err error) {
+	var nd net.Dialer
+	c, err := nd.DialContext(ctx, "tcp", proxyAddr(proxyURL))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			c.Close()
+		}
+	}()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+	}
+	if proxyURL.Scheme == "https" {
+		tc := tls.Client(c, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err = tc.HandshakeContext(ctx); err != nil {
+			return
+		}
+		c = tc
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u := proxyURL.User; u != nil {
+		password, _ := u.Password()
+		cred := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+cred)
+	}
+	if err = req.Write(c); err != nil {
+		return
+	}
+
+	br := bufio.NewReader(c)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Proxy CONNECT to %s failed: %s", addr, resp.Status)
+	}
+	c.SetDeadline(time.Time{})
+
+	if br.Buffered() > 0 {
+		return &bufferedConn{c, br}, nil
+	}
+	return c, nil
+}
+
+// proxyAddr returns the host:port address of the proxy, applying the
+// default port for the proxy scheme if none is given.
+func proxyAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "80"
+	switch u.Scheme {
+	case "https":
+		port = "443"
+	case "socks5", "socks5h":
+		port = "1080"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// bufferedConn returns data read from the proxy beyond its CONNECT
+// response before reading further from the connection.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (b *bufferedConn) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
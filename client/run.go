@@ -0,0 +1,207 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/farsightsec/sielink"
+	"github.com/farsightsec/sielink/rawlink"
+)
+
+var errNoServers = errors.New("No servers configured")
+
+// Retryable reports whether a connection which ended with err should be
+// reattempted. Version mismatches and rejected credentials are not retried,
+// nor are other fatal alerts from the server, unless they report that the
+// server is unavailable or timed out the connection's heartbeats. Errors
+// reporting that the local Link is closed, shut down or finished are not
+// retried.
+func Retryable(err error) bool {
+	if errors.Is(err, rawlink.ErrVersionMismatch) || errors.Is(err, rawlink.ErrAuthRejected) {
+		return false
+	}
+	if errors.Is(err, rawlink.ErrLinkClosed) || errors.Is(err, rawlink.ErrLinkShutdown) ||
+		errors.Is(err, rawlink.ErrLinkFinished) {
+		return false
+	}
+	var alert *sielink.Alert
+	if errors.As(err, &alert) && alert.GetLevel() == sielink.AlertLevel_FatalError {
+		switch alert.GetCode() {
+		case sielink.AlertCodeUnavailable, sielink.AlertCodeHeartbeatTimeout:
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// Run maintains Config.Connections concurrent connections to the servers
+// listed in Config.Servers, reconnecting with exponential backoff when a
+// connection ends, and without delay when a server shuts a connection
+// down and another server is configured. It returns nil when Stop is
+// called or the Link leaves the running state, or the error which ended
+// a connection if that error is not Retryable.
+func (c *basicClient) Run() error {
+	if len(c.Servers) == 0 {
+		return errNoServers
+	}
+	n := c.Config.Connections
+	if n <= 0 {
+		n = 1
+	}
+
+	var wg sync.WaitGroup
+	errc := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(slot int) {
+			defer wg.Done()
+			if err := c.maintainConnection(slot); err != nil {
+				errc <- err
+			}
+		}(i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case err := <-errc:
+		c.Stop()
+		<-done
+		return err
+	case <-done:
+		return nil
+	}
+}
+
+// stopped reports whether Stop has been called, or the Link has been
+// closed, shut down or finished, so that no further connections should
+// be made.
+func (c *basicClient) stopped() bool {
+	select {
+	case <-c.stop:
+		return true
+	default:
+	}
+	return c.Link.Err() != nil
+}
+
+// maintainConnection runs one connection slot, cycling through the
+// configured servers starting at an offset of slot so concurrent
+// connections are spread across servers. When a server requests that the
+// client shut down a connection, the slot connects to the next server at
+// once, while the old connection finishes sending and closes. With only
+// one server configured, the slot instead waits for the connection to
+// close and reconnects as usual.
+func (c *basicClient) maintainConnection(slot int) error {
+	var draining sync.WaitGroup
+	defer draining.Wait()
+
+	// fatal receives errors from connections which ended after the
+	// slot migrated away from them, if those errors are not Retryable.
+	fatal := make(chan error, 1)
+
+	b := newBackoff(c.MinBackoff, c.MaxBackoff)
+	for i := slot; !c.stopped(); i++ {
+		start := time.Now()
+		tc := newConnection(c.Servers[i%len(c.Servers)])
+		errc := make(chan error, 1)
+		draining.Add(1)
+		go func() {
+			defer draining.Done()
+			errc <- c.dialAndHandleSRV(tc)
+		}()
+
+		var shutdown <-chan struct{}
+		if len(c.Servers) > 1 {
+			shutdown = tc.shutdown
+		}
+
+		var err error
+		select {
+		case err = <-errc:
+		case err = <-fatal:
+			return err
+		case <-shutdown:
+			c.Link.Logger.Info("server shutting down, migrating connection",
+				"server", tc.server)
+			draining.Add(1)
+			go func() {
+				defer draining.Done()
+				c.drained(tc, <-errc, fatal)
+			}()
+			b.reset()
+			continue
+		}
+		if c.stopped() {
+			return nil
+		}
+		if !Retryable(err) {
+			c.Link.Logger.Error("connection rejected, not retrying",
+				"server", tc.server, "err", err)
+			return err
+		}
+		// A connection which stayed up for longer than the maximum
+		// backoff interval is considered to have succeeded, and
+		// the next failure starts the backoff sequence over.
+		if time.Since(start) > b.max {
+			b.reset()
+		}
+		delay := b.next()
+		c.Link.Logger.Info("reconnecting", "server", tc.server, "delay", delay)
+		select {
+		case <-c.stop:
+			return nil
+		case <-c.Done():
+			return nil
+		case err = <-fatal:
+			return err
+		case <-time.After(delay):
+		}
+	}
+	return nil
+}
+
+// drained reports the error which ended a connection the slot migrated
+// away from, passing it on through fatal if it is not Retryable.
+func (c *basicClient) drained(tc *connection, err error, fatal chan<- error) {
+	if c.stopped() {
+		return
+	}
+	if err == nil {
+		c.Link.Logger.Debug("migrated connection closed", "server", tc.server)
+		return
+	}
+	if Retryable(err) {
+		c.Link.Logger.Info("migrated connection closed",
+			"server", tc.server, "err", err)
+		return
+	}
+	c.Link.Logger.Error("migrated connection rejected, not retrying",
+		"server", tc.server, "err", err)
+	select {
+	case fatal <- err:
+	default:
+	}
+}
+
+// Stop ends a running Run loop and closes all connections.
+func (c *basicClient) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+		c.Close()
+	})
+}
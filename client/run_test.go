@@ -0,0 +1,223 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package client
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/websocket"
+
+	"github.com/farsightsec/sielink"
+	"github.com/farsightsec/sielink/rawlink"
+)
+
+type testServer struct {
+	link     *rawlink.Link
+	ts       *httptest.Server
+	received int32
+	accepted int32
+}
+
+func newTestServer() *testServer {
+	s := &testServer{link: rawlink.NewLink()}
+	s.ts = httptest.NewServer(websocket.Handler(func(c *websocket.Conn) {
+		atomic.AddInt32(&s.accepted, 1)
+		s.link.HandleConnection(c)
+	}))
+	go func() {
+		for range s.link.Receive() {
+			atomic.AddInt32(&s.received, 1)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) url() string {
+	return strings.Replace(s.ts.URL, "http://", "ws://", 1)
+}
+
+// Send data continuously while the first server drains its connections,
+// and verify that the client migrates to the second server without
+// losing any payloads.
+func TestRunMigrate(t *testing.T) {
+	a, b := newTestServer(), newTestServer()
+	defer a.ts.Close()
+	defer b.ts.Close()
+
+	cl := NewClient(&Config{
+		URL:        "http://localhost/TestRunMigrate",
+		Servers:    []string{a.url(), b.url()},
+		MinBackoff: 10 * time.Millisecond,
+	})
+	runc := make(chan error, 1)
+	go func() { runc <- cl.Run() }()
+	select {
+	case <-cl.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("client did not connect")
+	}
+
+	const n = 200
+	sent := make(chan int, 1)
+	go func() {
+		count := 0
+		for i := 0; i < n; i++ {
+			if cl.Send(&sielink.Payload{Channel: proto.Uint32(1)}) == nil {
+				count++
+			}
+			time.Sleep(time.Millisecond)
+		}
+		sent <- count
+	}()
+
+	// The client should move to the second server while the first
+	// is shutting down, before it finishes.
+	time.Sleep(50 * time.Millisecond)
+	a.link.Shutdown()
+	err := waitForCount(time.Second, func() int32 {
+		return atomic.LoadInt32(&b.received)
+	}, 1)
+	if err != nil {
+		t.Fatal("client did not migrate to second server")
+	}
+	if len(a.link.Connections()) != 1 {
+		t.Error("connection to first server closed before migration")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if unsent, err := a.link.Drain(ctx); err != nil {
+		t.Fatalf("server Drain returned %d, %v", unsent, err)
+	}
+
+	var count int
+	select {
+	case count = <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out sending")
+	}
+	if count != n {
+		t.Errorf("%d of %d payloads sent", count, n)
+	}
+	err = waitForCount(time.Second, func() int32 {
+		return atomic.LoadInt32(&a.received) + atomic.LoadInt32(&b.received)
+	}, n)
+	if err != nil {
+		t.Errorf("servers received %d and %d payloads, expected %d in total",
+			atomic.LoadInt32(&a.received), atomic.LoadInt32(&b.received), n)
+	}
+	if atomic.LoadInt32(&a.received) == 0 {
+		t.Error("no payloads sent to first server")
+	}
+
+	cl.Stop()
+	if err := <-runc; err != nil {
+		t.Errorf("Run returned %v", err)
+	}
+	b.link.Close()
+}
+
+// With a single server configured, verify that the client does not
+// reconnect to it while the old connection is shutting down.
+func TestRunShutdownSingleServer(t *testing.T) {
+	a := newTestServer()
+	defer a.ts.Close()
+
+	cl := NewClient(&Config{
+		URL:        "http://localhost/TestRunShutdownSingleServer",
+		Servers:    []string{a.url()},
+		MinBackoff: 10 * time.Millisecond,
+	})
+	runc := make(chan error, 1)
+	go func() { runc <- cl.Run() }()
+	select {
+	case <-cl.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("client did not connect")
+	}
+
+	a.link.Shutdown()
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&a.accepted); n != 1 {
+		t.Errorf("%d connections to server during shutdown, expected 1", n)
+	}
+
+	cl.Stop()
+	if err := <-runc; err != nil {
+		t.Errorf("Run returned %v", err)
+	}
+	a.link.Close()
+}
+
+// Close and shut down the client's Link directly, without Stop, and
+// verify that Run returns without reconnecting.
+func TestRunLinkClosed(t *testing.T) {
+	for _, end := range []struct {
+		name string
+		f    func(cl Client)
+	}{
+		{"Close", func(cl Client) { cl.Close() }},
+		{"Shutdown", func(cl Client) { cl.(*basicClient).Shutdown() }},
+	} {
+		t.Run(end.name, func(t *testing.T) {
+			a := newTestServer()
+			defer a.ts.Close()
+			defer a.link.Close()
+
+			cl := NewClient(&Config{
+				URL:        "http://localhost/TestRunLinkClosed",
+				Servers:    []string{a.url()},
+				MinBackoff: 10 * time.Millisecond,
+				MaxBackoff: 20 * time.Millisecond,
+			})
+			runc := make(chan error, 1)
+			go func() { runc <- cl.Run() }()
+			select {
+			case <-cl.Ready():
+			case <-time.After(time.Second):
+				t.Fatal("client did not connect")
+			}
+
+			end.f(cl)
+			if end.name == "Shutdown" {
+				// The server ends the connection once the
+				// client has shut down.
+				a.link.Close()
+			}
+			select {
+			case err := <-runc:
+				if err != nil {
+					t.Errorf("Run returned %v", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Run did not return")
+			}
+			if n := atomic.LoadInt32(&a.accepted); n != 1 {
+				t.Errorf("%d connections to server, expected 1", n)
+			}
+		})
+	}
+}
+
+// waitForCount waits for f to return at least n.
+func waitForCount(timeout time.Duration, f func() int32, n int32) error {
+	deadline := time.Now().Add(timeout)
+	for f() < n {
+		if time.Now().After(deadline) {
+			return context.DeadlineExceeded
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return nil
+}
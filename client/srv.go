@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const defaultSRVRefresh = 5 * time.Minute
+
+// A Resolver looks up DNS SRV and address records. It is satisfied by
+// *net.Resolver, and may be replaced in the client Config for testing or
+// to use a specific name server.
+type Resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (cname string, addrs []*net.SRV, err error)
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+func (c *basicClient) resolver() Resolver {
+	if c.Resolver != nil {
+		return c.Resolver
+	}
+	return net.DefaultResolver
+}
+
+func (c *basicClient) DialAndHandleSRV(serverurl string) error {
+	return c.dialAndHandleSRV(newConnection(serverurl))
+}
+
+func (c *basicClient) dialAndHandleSRV(tc *connection) error {
+	conf, err := c.websocketConfig(tc.server)
+	if err != nil {
+		return err
+	}
+
+	host := conf.Location.Host
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return c.dialAndHandle(tc)
+	}
+
+	c.conns.dialing(tc)
+	conn, err := c.dialSRV(conf)
+	return c.handle(tc, conn, err)
+}
+
+func (c *basicClient) dialSRV(conf *websocket.Config) (*websocket.Conn, error) {
+	host := conf.Location.Host
+	service, port, err := schemeDefaults(conf.Location.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := c.SRVRefresh
+	if refresh <= 0 {
+		refresh = defaultSRVRefresh
+	}
+	srvs, err := c.srv.lookup(context.Background(), c.resolver(), service, host, refresh)
+	if err != nil {
+		if t, ok := err.(*net.DNSError); ok && t.Temporary() {
+			return nil, err
+		}
+	}
+
+	addrs := srvAddrs(orderSRV(srvs))
+	if len(addrs) == 0 {
+		addrs = []string{fmt.Sprintf("%s:%d", host, port)}
+	}
+
+	conn, err := c.dialer().dialAddrs(conf, addrs, host)
+	if err != nil {
+		// None of the targets accepted a connection. Discard the
+		// cached records so the next attempt picks up any changes.
+		c.srv.invalidate(service, host)
+		return nil, err
+	}
+	return conn, nil
+}
+
+// srvCache holds the results of SRV lookups until they are due to be
+// refreshed.
+type srvCache struct {
+	mutex   sync.Mutex
+	entries map[string]srvEntry
+}
+
+type srvEntry struct {
+	srvs    []*net.SRV
+	expires time.Time
+}
+
+func (s *srvCache) lookup(ctx context.Context, r Resolver, service, name string, refresh time.Duration) ([]*net.SRV, error) {
+	key := service + "." + name
+	s.mutex.Lock()
+	e, ok := s.entries[key]
+	s.mutex.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.srvs, nil
+	}
+
+	_, srvs, err := r.LookupSRV(ctx, service, "tcp", name)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.entries == nil {
+		s.entries = make(map[string]srvEntry)
+	}
+	s.entries[key] = srvEntry{srvs, time.Now().Add(refresh)}
+	return srvs, nil
+}
+
+func (s *srvCache) invalidate(service, name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.entries, service+"."+name)
+}
+
+// orderSRV returns a copy of srvs sorted by ascending priority, with
+// records of equal priority in a random order selected by weight as
+// described in RFC 2782.
+func orderSRV(srvs []*net.SRV) []*net.SRV {
+	res := make([]*net.SRV, len(srvs))
+	copy(res, srvs)
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Priority < res[j].Priority
+	})
+
+	for i := 0; i < len(res); {
+		j := i + 1
+		for j < len(res) && res[j].Priority == res[i].Priority {
+			j++
+		}
+		shuffleByWeight(res[i:j])
+		i = j
+	}
+	return res
+}
+
+func shuffleByWeight(srvs []*net.SRV) {
+	sum := 0
+	for _, s := range srvs {
+		sum += int(s.Weight)
+	}
+	for i := range srvs {
+		if sum == 0 {
+			rand.Shuffle(len(srvs)-i, func(a, b int) {
+				srvs[i+a], srvs[i+b] = srvs[i+b], srvs[i+a]
+			})
+			return
+		}
+		n := rand.Intn(sum + 1)
+		for j := i; j < len(srvs); j++ {
+			if n -= int(srvs[j].Weight); n <= 0 {
+				srvs[i], srvs[j] = srvs[j], srvs[i]
+				break
+			}
+		}
+		sum -= int(srvs[i].Weight)
+	}
+}
+
+func srvAddrs(srvs []*net.SRV) (addrs []string) {
+	for _, s := range srvs {
+		addrs = append(addrs, fmt.Sprintf("%s:%d", s.Target, s.Port))
+	}
+	return
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package client
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/farsightsec/sielink/rawlink"
+)
+
+type testResolver struct {
+	mutex   sync.Mutex
+	srvs    []*net.SRV
+	lookups int
+}
+
+func (r *testResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.lookups++
+	return name, r.srvs, nil
+}
+
+func (r *testResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return []net.IPAddr{{IP: net.IPv4(127, 0, 0, 1)}}, nil
+}
+
+func (r *testResolver) count() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.lookups
+}
+
+func TestOrderSRV(t *testing.T) {
+	srvs := []*net.SRV{
+		{Target: "c", Priority: 20, Weight: 10},
+		{Target: "a1", Priority: 10, Weight: 0},
+		{Target: "b", Priority: 15, Weight: 10},
+		{Target: "a2", Priority: 10, Weight: 50},
+	}
+	for i := 0; i < 100; i++ {
+		o := orderSRV(srvs)
+		if len(o) != len(srvs) {
+			t.Fatalf("orderSRV returned %d records, expected %d", len(o), len(srvs))
+		}
+		if o[0].Priority != 10 || o[1].Priority != 10 {
+			t.Fatal("orderSRV did not place lowest priority first")
+		}
+		if o[2].Target != "b" || o[3].Target != "c" {
+			t.Fatal("orderSRV did not sort by priority")
+		}
+	}
+}
+
+func TestSRVFailover(t *testing.T) {
+	srvLink := rawlink.NewLink()
+	defer srvLink.Close()
+	ts := httptest.NewServer(websocket.Handler(func(c *websocket.Conn) {
+		srvLink.HandleConnection(c)
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	// Reserve a port with nothing listening on it.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadPort := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	r := &testResolver{srvs: []*net.SRV{
+		{Target: "127.0.0.1", Port: uint16(port), Priority: 20},
+		{Target: "127.0.0.1", Port: uint16(deadPort), Priority: 10},
+	}}
+	cl := NewClient(&Config{
+		URL:        "http://localhost/TestSRVFailover",
+		Resolver:   r,
+		SRVRefresh: time.Hour,
+	})
+
+	errc := make(chan error, 1)
+	go func() { errc <- cl.DialAndHandleSRV("ws://sie.test/session") }()
+	select {
+	case <-cl.Ready():
+	case err := <-errc:
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for connection")
+	}
+	cl.Close()
+	<-errc
+
+	// The Link is closed, so this connection ends immediately, but
+	// must be made using the cached SRV records.
+	cl.DialAndHandleSRV("ws://sie.test/session")
+	if n := r.count(); n != 1 {
+		t.Errorf("SRV records looked up %d times, expected 1", n)
+	}
+}
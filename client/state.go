@@ -0,0 +1,215 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package client
+
+import (
+	"sync"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/farsightsec/sielink/rawlink"
+)
+
+// stateBuffer is the number of StateEvents buffered for each watcher.
+// Events are discarded for watchers which fall this far behind.
+const stateBuffer = 64
+
+// A StateEvent reports a change in the state of one of the client's
+// connections.
+type StateEvent struct {
+	// Server is the URL of the server to which the connection was made.
+	Server string
+	// State is the new state of the connection.
+	State rawlink.ConnState
+	// Err is the error which ended the connection, if any, for
+	// connections entering StateClosed.
+	Err error
+	// Aggregate is the aggregate state of the client after this
+	// change.
+	Aggregate rawlink.ConnState
+}
+
+// connection tracks the state of a single client connection.
+type connection struct {
+	server string
+	state  rawlink.ConnState
+
+	// shutdown is closed when the server requests that the client
+	// shut down the connection.
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+}
+
+func newConnection(server string) *connection {
+	return &connection{server: server, shutdown: make(chan struct{})}
+}
+
+// connTracker maintains the state of all client connections, and
+// the Ready channel reflecting whether any are established.
+type connTracker struct {
+	mutex    sync.Mutex
+	conns    map[*connection]struct{}
+	ws       map[*websocket.Conn]*connection
+	ready    chan struct{}
+	isReady  bool
+	watchers map[chan StateEvent]struct{}
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{
+		conns:    make(map[*connection]struct{}),
+		ws:       make(map[*websocket.Conn]*connection),
+		ready:    make(chan struct{}),
+		watchers: make(map[chan StateEvent]struct{}),
+	}
+}
+
+// dialing registers conn in StateDialing.
+func (t *connTracker) dialing(conn *connection) {
+	t.mutex.Lock()
+	t.conns[conn] = struct{}{}
+	t.mutex.Unlock()
+	t.set(conn, rawlink.StateDialing, nil)
+}
+
+// attach associates a dialed websocket connection with its tracked
+// connection, so that state changes reported by the Link are applied.
+func (t *connTracker) attach(conn *connection, c *websocket.Conn) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.ws[c] = conn
+}
+
+// linkState receives state changes from the Link's StateFunc.
+func (t *connTracker) linkState(lc *rawlink.Conn, s rawlink.ConnState, err error) {
+	t.mutex.Lock()
+	conn, ok := t.ws[lc.WebSocket()]
+	if s == rawlink.StateClosed {
+		delete(t.ws, lc.WebSocket())
+	}
+	t.mutex.Unlock()
+	if ok {
+		t.set(conn, s, err)
+	}
+}
+
+// linkShutdown receives shutdown requests from the Link's ShutdownFunc.
+func (t *connTracker) linkShutdown(lc *rawlink.Conn) {
+	t.mutex.Lock()
+	conn, ok := t.ws[lc.WebSocket()]
+	t.mutex.Unlock()
+	if ok {
+		conn.shutdownOnce.Do(func() { close(conn.shutdown) })
+	}
+}
+
+// server returns the URL of the server to which lc is connected.
+func (t *connTracker) server(lc *rawlink.Conn) string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if conn, ok := t.ws[lc.WebSocket()]; ok {
+		return conn.server
+	}
+	return ""
+}
+
+func (t *connTracker) set(conn *connection, s rawlink.ConnState, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if _, ok := t.conns[conn]; !ok || conn.state == s {
+		return
+	}
+	conn.state = s
+	if s == rawlink.StateClosed {
+		delete(t.conns, conn)
+	}
+
+	agg := t.aggregate()
+	switch {
+	case agg == rawlink.StateEstablished && !t.isReady:
+		close(t.ready)
+		t.isReady = true
+	case agg != rawlink.StateEstablished && t.isReady:
+		t.ready = make(chan struct{})
+		t.isReady = false
+	}
+
+	ev := StateEvent{Server: conn.server, State: s, Err: err, Aggregate: agg}
+	for w := range t.watchers {
+		select {
+		case w <- ev:
+		default:
+		}
+	}
+}
+
+// aggregate returns the most advanced state of any connection, preferring
+// established connections over those in the process of being established,
+// and those over draining connections. It must be called with t.mutex held.
+func (t *connTracker) aggregate() rawlink.ConnState {
+	rank := map[rawlink.ConnState]int{
+		rawlink.StateEstablished: 4,
+		rawlink.StateHandshaking: 3,
+		rawlink.StateDialing:     2,
+		rawlink.StateDraining:    1,
+	}
+	agg := rawlink.StateClosed
+	for conn := range t.conns {
+		if rank[conn.state] > rank[agg] {
+			agg = conn.state
+		}
+	}
+	return agg
+}
+
+func (t *connTracker) state() rawlink.ConnState {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.aggregate()
+}
+
+func (t *connTracker) readyChan() <-chan struct{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.ready
+}
+
+func (t *connTracker) watch() (<-chan StateEvent, func()) {
+	w := make(chan StateEvent, stateBuffer)
+	t.mutex.Lock()
+	t.watchers[w] = struct{}{}
+	t.mutex.Unlock()
+	var once sync.Once
+	return w, func() {
+		once.Do(func() {
+			t.mutex.Lock()
+			delete(t.watchers, w)
+			t.mutex.Unlock()
+			close(w)
+		})
+	}
+}
+
+func (c *basicClient) State() rawlink.ConnState {
+	return c.conns.state()
+}
+
+func (c *basicClient) WatchState() (<-chan StateEvent, func()) {
+	return c.conns.watch()
+}
+
+// Connections resolves the ambiguity between Link.Connections and
+// Config.Connections.
+func (c *basicClient) Connections() []rawlink.ConnInfo {
+	return c.Link.Connections()
+}
+
+func (c *basicClient) Ready() <-chan struct{} {
+	return c.conns.readyChan()
+}
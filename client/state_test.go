@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package client
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/farsightsec/sielink/rawlink"
+)
+
+// Connect to a server which closes the connection after the handshake.
+// Verify the sequence of state events, and that Ready is closed while
+// the connection is established and re-armed after it closes.
+func TestConnState(t *testing.T) {
+	srvLink := rawlink.NewLink()
+	ts := httptest.NewServer(websocket.Handler(func(c *websocket.Conn) {
+		srvLink.HandleConnection(c)
+	}))
+	defer ts.Close()
+
+	cl := NewClient(&Config{URL: "http://localhost/TestConnState"})
+	events, stop := cl.WatchState()
+	defer stop()
+
+	ready := cl.Ready()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- cl.DialAndHandle(strings.Replace(ts.URL, "http://", "ws://", 1))
+	}()
+
+	expected := []rawlink.ConnState{
+		rawlink.StateDialing,
+		rawlink.StateHandshaking,
+		rawlink.StateEstablished,
+	}
+	for _, s := range expected {
+		select {
+		case ev := <-events:
+			if ev.State != s {
+				t.Fatalf("received state %v, expected %v", ev.State, s)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for state %v", s)
+		}
+	}
+	select {
+	case <-ready:
+	case <-time.After(time.Second):
+		t.Fatal("Ready not closed after handshake")
+	}
+
+	srvLink.Close()
+	<-errc
+	for ev := range events {
+		if ev.State == rawlink.StateClosed {
+			if ev.Aggregate != rawlink.StateClosed {
+				t.Errorf("aggregate state %v after close", ev.Aggregate)
+			}
+			break
+		}
+	}
+	select {
+	case <-cl.Ready():
+		t.Error("Ready not re-armed after connection closed")
+	default:
+	}
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package client
+
+import (
+	"sync"
+
+	"github.com/farsightsec/sielink"
+	"github.com/farsightsec/sielink/rawlink"
+)
+
+const defaultStreamBuffer = 100
+
+// streamKey selects the payloads delivered to a receive stream. A stream
+// may select payloads by channel, by payload type, or by both.
+type streamKey struct {
+	channel   uint32
+	ptype     sielink.PayloadType
+	byChannel bool
+	byType    bool
+}
+
+// streams delivers received payloads to per-channel and per-type receive
+// streams, each with its own buffer. Payloads are routed directly from the
+// goroutines reading each connection, and payloads matching no stream are
+// forwarded to the client's Receive channel.
+type streams struct {
+	mutex  sync.Mutex
+	chans  map[streamKey]chan *sielink.Payload
+	buffer int
+	closed bool
+	recv   chan *sielink.Payload
+
+	// done is closed when the Link is closed, after which payloads
+	// which cannot be delivered at once are discarded.
+	done <-chan struct{}
+}
+
+func newStreams(buffer int, done <-chan struct{}) *streams {
+	if buffer <= 0 {
+		buffer = defaultStreamBuffer
+	}
+	return &streams{
+		chans:  make(map[streamKey]chan *sielink.Payload),
+		buffer: buffer,
+		recv:   make(chan *sielink.Payload),
+		done:   done,
+	}
+}
+
+func (s *streams) stream(key streamKey) <-chan *sielink.Payload {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ch, ok := s.chans[key]
+	if !ok {
+		ch = make(chan *sielink.Payload, s.buffer)
+		if s.closed {
+			close(ch)
+		}
+		s.chans[key] = ch
+	}
+	return ch
+}
+
+// lookup returns the most specific stream matching p, if any.
+func (s *streams) lookup(p *sielink.Payload) chan *sielink.Payload {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.chans) == 0 {
+		return nil
+	}
+	channel, ptype := p.GetChannel(), p.GetPayloadType()
+	for _, key := range []streamKey{
+		{channel: channel, ptype: ptype, byChannel: true, byType: true},
+		{channel: channel, byChannel: true},
+		{ptype: ptype, byType: true},
+	} {
+		if ch, ok := s.chans[key]; ok {
+			return ch
+		}
+	}
+	return nil
+}
+
+// route is the Link's ReceiveFunc. It delivers p to its stream, blocking
+// if that stream's buffer is full, until the connection or Link closes.
+func (s *streams) route(c *rawlink.Conn, p *sielink.Payload) bool {
+	return s.deliver(p, c.Done())
+}
+
+// deliver sends p to its stream, if any, reporting whether it did so or
+// discarded p because connDone or s.done was closed first.
+func (s *streams) deliver(p *sielink.Payload, connDone <-chan struct{}) bool {
+	ch := s.lookup(p)
+	if ch == nil {
+		return false
+	}
+	select {
+	case ch <- p:
+	case <-connDone:
+	case <-s.done:
+	}
+	return true
+}
+
+// forward copies unrouted payloads from the Link to the client's Receive
+// channel, discarding them once the Link is closed if they are not read.
+// Once the Link's receive channel closes, no further payloads will be
+// routed, and forward closes all streams.
+func (s *streams) forward(linkRecv <-chan *sielink.Payload) {
+	for p := range linkRecv {
+		select {
+		case s.recv <- p:
+		case <-s.done:
+		}
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	for _, ch := range s.chans {
+		close(ch)
+	}
+	close(s.recv)
+}
+
+func (c *basicClient) Receive() <-chan *sielink.Payload {
+	return c.streams.recv
+}
+
+func (c *basicClient) ReceiveChannel(channel uint32) <-chan *sielink.Payload {
+	return c.streams.stream(streamKey{channel: channel, byChannel: true})
+}
+
+func (c *basicClient) ReceiveType(ptype sielink.PayloadType) <-chan *sielink.Payload {
+	return c.streams.stream(streamKey{ptype: ptype, byType: true})
+}
+
+func (c *basicClient) ReceiveChannelType(channel uint32, ptype sielink.PayloadType) <-chan *sielink.Payload {
+	return c.streams.stream(streamKey{
+		channel:   channel,
+		ptype:     ptype,
+		byChannel: true,
+		byType:    true,
+	})
+}
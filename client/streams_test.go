@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/farsightsec/sielink"
+)
+
+func TestStreams(t *testing.T) {
+	s := newStreams(4, nil)
+	linkRecv := make(chan *sielink.Payload, 1)
+	go s.forward(linkRecv)
+
+	ch5 := s.stream(streamKey{channel: 5, byChannel: true})
+	if s.stream(streamKey{channel: 5, byChannel: true}) != ch5 {
+		t.Error("repeated stream request returned a different channel")
+	}
+	ty := s.stream(streamKey{ptype: sielink.PayloadType_NmsgContainer, byType: true})
+	ch5ty := s.stream(streamKey{channel: 5, ptype: sielink.PayloadType_NmsgContainer,
+		byChannel: true, byType: true})
+
+	deliver := func(channel uint32, ptype sielink.PayloadType) *sielink.Payload {
+		p := &sielink.Payload{Channel: &channel, PayloadType: &ptype}
+		if !s.deliver(p, nil) {
+			linkRecv <- p
+		}
+		return p
+	}
+	for _, tc := range []struct {
+		name   string
+		p      *sielink.Payload
+		stream <-chan *sielink.Payload
+	}{
+		{"channel and type", deliver(5, sielink.PayloadType_NmsgContainer), ch5ty},
+		{"channel", deliver(5, sielink.PayloadType_LogRecord), ch5},
+		{"type", deliver(6, sielink.PayloadType_NmsgContainer), ty},
+		{"unrouted", deliver(6, sielink.PayloadType_LogRecord), s.recv},
+	} {
+		if p := <-tc.stream; p != tc.p {
+			t.Errorf("%s: received %v, expected %v", tc.name, p, tc.p)
+		}
+	}
+
+	close(linkRecv)
+	if _, ok := <-ch5; ok {
+		t.Error("stream not closed with link")
+	}
+	if _, ok := <-s.recv; ok {
+		t.Error("receive channel not closed with link")
+	}
+}
+
+// Fill a stream which is never read, and leave Receive unread, then
+// verify that closing the client releases the connection's reader and
+// closes the streams.
+func TestStreamsClose(t *testing.T) {
+	srv := newTestServer()
+	defer srv.ts.Close()
+	defer srv.link.Close()
+
+	cl := NewClient(&Config{
+		URL:          "http://localhost/TestStreamsClose",
+		StreamBuffer: 2,
+	})
+	ch5 := cl.ReceiveChannel(5)
+	cl.Subscribe(5, 6)
+	go cl.DialAndHandle(srv.url())
+	err := waitForCount(time.Second, func() int32 {
+		conns := srv.link.Connections()
+		if len(conns) != 1 || len(conns[0].Subscription) == 0 {
+			return 0
+		}
+		return 1
+	}, 1)
+	if err != nil {
+		t.Fatal("client did not subscribe")
+	}
+
+	for i := 0; i < 10; i++ {
+		for _, ch := range []uint32{5, 6} {
+			if err := srv.link.Send(&sielink.Payload{Channel: proto.Uint32(ch)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	err = waitForCount(time.Second, func() int32 {
+		return int32(len(ch5))
+	}, 2)
+	if err != nil {
+		t.Fatal("stream did not fill")
+	}
+
+	cl.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := cl.(*basicClient).Wait(ctx); err != nil {
+		t.Fatalf("Wait returned %v", err)
+	}
+	// The streams close although Receive is not read.
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-ch5:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("stream not closed")
+		}
+	}
+}
@@ -0,0 +1,253 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package client
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/farsightsec/sielink"
+)
+
+// subscriptions holds the channels requested by the client, keyed by
+// source site. Site zero requests data from any site. Changes are passed
+// to apply, which is called with the mutex held so that concurrent changes
+// are applied in order, and returns the serial number of the subscription.
+type subscriptions struct {
+	mutex sync.Mutex
+	sites map[uint32]*siteSubscription
+	apply func([]*sielink.Subscription) uint32
+}
+
+type siteSubscription struct {
+	channels map[uint32]struct{}
+	ranges   []channelRange
+	all      bool
+}
+
+type channelRange struct{ first, last uint32 }
+
+func (ss *siteSubscription) empty() bool {
+	return !ss.all && len(ss.channels) == 0 && len(ss.ranges) == 0
+}
+
+// site returns the subscription for site, creating it if necessary.
+// It must be called with s.mutex held.
+func (s *subscriptions) site(site uint32) *siteSubscription {
+	if s.sites == nil {
+		s.sites = make(map[uint32]*siteSubscription)
+	}
+	ss, ok := s.sites[site]
+	if !ok {
+		ss = &siteSubscription{channels: make(map[uint32]struct{})}
+		s.sites[site] = ss
+	}
+	return ss
+}
+
+// set replaces all subscriptions with one to channels from any site.
+func (s *subscriptions) set(channels []uint32) uint32 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sites = nil
+	ss := s.site(0)
+	for _, ch := range channels {
+		ss.channels[ch] = struct{}{}
+	}
+	return s.apply(s.list())
+}
+
+func (s *subscriptions) add(site uint32, channels []uint32) uint32 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ss := s.site(site)
+	for _, ch := range channels {
+		ss.channels[ch] = struct{}{}
+	}
+	return s.apply(s.list())
+}
+
+func (s *subscriptions) addRange(site, first, last uint32) uint32 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if first > last {
+		first, last = last, first
+	}
+	ss := s.site(site)
+	ss.ranges = mergeRange(ss.ranges, channelRange{first, last})
+	return s.apply(s.list())
+}
+
+func (s *subscriptions) addAll(site uint32) uint32 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.site(site).all = true
+	return s.apply(s.list())
+}
+
+func (s *subscriptions) remove(channels []uint32) uint32 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for site, ss := range s.sites {
+		for _, ch := range channels {
+			delete(ss.channels, ch)
+		}
+		if ss.empty() {
+			delete(s.sites, site)
+		}
+	}
+	return s.apply(s.list())
+}
+
+// removeRange cancels subscriptions to the channels from first to last,
+// including any part of a subscribed range which overlaps them.
+func (s *subscriptions) removeRange(first, last uint32) uint32 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if first > last {
+		first, last = last, first
+	}
+	for site, ss := range s.sites {
+		for ch := range ss.channels {
+			if ch >= first && ch <= last {
+				delete(ss.channels, ch)
+			}
+		}
+		var ranges []channelRange
+		for _, r := range ss.ranges {
+			if r.last < first || r.first > last {
+				ranges = append(ranges, r)
+				continue
+			}
+			if r.first < first {
+				ranges = append(ranges, channelRange{r.first, first - 1})
+			}
+			if r.last > last {
+				ranges = append(ranges, channelRange{last + 1, r.last})
+			}
+		}
+		ss.ranges = ranges
+		if ss.empty() {
+			delete(s.sites, site)
+		}
+	}
+	return s.apply(s.list())
+}
+
+func (s *subscriptions) removeAll() uint32 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sites = nil
+	return s.apply(s.list())
+}
+
+// mergeRange adds r to the sorted, non-overlapping ranges, coalescing
+// it with any ranges it overlaps or adjoins.
+func mergeRange(ranges []channelRange, r channelRange) []channelRange {
+	var res []channelRange
+	for _, o := range ranges {
+		switch {
+		case uint64(o.last)+1 < uint64(r.first):
+			res = append(res, o)
+		case uint64(r.last)+1 < uint64(o.first):
+			res = append(res, r)
+			r = o
+		default:
+			if o.first < r.first {
+				r.first = o.first
+			}
+			if o.last > r.last {
+				r.last = o.last
+			}
+		}
+	}
+	return append(res, r)
+}
+
+func (s *subscriptions) get() []*sielink.Subscription {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.list()
+}
+
+// list returns the subscriptions ordered by site, with channels in
+// ascending order. It must be called with s.mutex held.
+func (s *subscriptions) list() []*sielink.Subscription {
+	sites := make([]uint32, 0, len(s.sites))
+	for site := range s.sites {
+		sites = append(sites, site)
+	}
+	sort.Slice(sites, func(i, j int) bool { return sites[i] < sites[j] })
+
+	subs := make([]*sielink.Subscription, 0, len(sites))
+	for _, site := range sites {
+		ss := s.sites[site]
+		sub := &sielink.Subscription{}
+		if site != 0 {
+			sub.SourceSite = proto.Uint32(site)
+		}
+		if ss.all {
+			sub.AllChannels = proto.Bool(true)
+			subs = append(subs, sub)
+			continue
+		}
+		for ch := range ss.channels {
+			sub.Channel = append(sub.Channel, ch)
+		}
+		sort.Slice(sub.Channel, func(i, j int) bool {
+			return sub.Channel[i] < sub.Channel[j]
+		})
+		for _, r := range ss.ranges {
+			sub.Range = append(sub.Range, &sielink.ChannelRange{
+				First: proto.Uint32(r.first),
+				Last:  proto.Uint32(r.last),
+			})
+		}
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+func (c *basicClient) Subscribe(channels ...uint32) {
+	c.subs.set(channels)
+}
+
+func (c *basicClient) AddSubscription(channels ...uint32) {
+	c.subs.add(0, channels)
+}
+
+func (c *basicClient) SubscribeRange(first, last uint32) {
+	c.subs.addRange(0, first, last)
+}
+
+func (c *basicClient) SubscribeAll() {
+	c.subs.addAll(0)
+}
+
+func (c *basicClient) SubscribeSite(site uint32, channels ...uint32) {
+	c.subs.add(site, channels)
+}
+
+func (c *basicClient) Unsubscribe(channels ...uint32) {
+	c.subs.remove(channels)
+}
+
+func (c *basicClient) UnsubscribeRange(first, last uint32) {
+	c.subs.removeRange(first, last)
+}
+
+func (c *basicClient) UnsubscribeAll() {
+	c.subs.removeAll()
+}
+
+func (c *basicClient) Subscriptions() []*sielink.Subscription {
+	return c.subs.get()
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package client
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/farsightsec/sielink"
+	"github.com/farsightsec/sielink/rawlink"
+)
+
+func TestSubscriptions(t *testing.T) {
+	var applied []*sielink.Subscription
+	s := subscriptions{apply: func(subs []*sielink.Subscription) uint32 {
+		applied = subs
+		return 0
+	}}
+
+	s.add(0, []uint32{5, 3})
+	s.add(7, []uint32{204})
+	s.add(0, []uint32{4})
+	if len(applied) != 2 {
+		t.Fatalf("applied %d subscriptions, expected 2", len(applied))
+	}
+	if applied[0].SourceSite != nil || len(applied[0].Channel) != 3 ||
+		applied[0].Channel[0] != 3 || applied[0].Channel[2] != 5 {
+		t.Errorf("incorrect any-site subscription: %v", applied[0])
+	}
+	if applied[1].GetSourceSite() != 7 || len(applied[1].Channel) != 1 {
+		t.Errorf("incorrect site subscription: %v", applied[1])
+	}
+
+	s.remove([]uint32{204, 3})
+	if len(applied) != 1 {
+		t.Fatalf("applied %d subscriptions after remove, expected 1", len(applied))
+	}
+	if len(applied[0].Channel) != 2 || applied[0].Channel[0] != 4 {
+		t.Errorf("incorrect subscription after remove: %v", applied[0])
+	}
+	if len(s.get()) != 1 {
+		t.Error("effective subscriptions do not match applied")
+	}
+}
+
+func TestSubscriptionSet(t *testing.T) {
+	var applied []*sielink.Subscription
+	s := subscriptions{apply: func(subs []*sielink.Subscription) uint32 {
+		applied = subs
+		return 0
+	}}
+
+	s.add(7, []uint32{204})
+	s.addRange(0, 200, 299)
+	s.set([]uint32{5})
+	if len(applied) != 1 || applied[0].SourceSite != nil ||
+		len(applied[0].Channel) != 1 || applied[0].Channel[0] != 5 ||
+		len(applied[0].Range) != 0 {
+		t.Fatalf("subscriptions not replaced: %v", applied)
+	}
+	s.add(0, []uint32{6})
+	if len(applied[0].Channel) != 2 {
+		t.Errorf("subscription not added after replace: %v", applied[0])
+	}
+}
+
+func TestSubscriptionRanges(t *testing.T) {
+	var applied []*sielink.Subscription
+	s := subscriptions{apply: func(subs []*sielink.Subscription) uint32 {
+		applied = subs
+		return 0
+	}}
+
+	s.addRange(0, 200, 299)
+	s.addRange(0, 300, 310)
+	s.addRange(0, 100, 150)
+	if len(applied) != 1 || len(applied[0].Range) != 2 {
+		t.Fatalf("incorrect merged ranges: %v", applied)
+	}
+	if r := applied[0].Range[1]; r.GetFirst() != 200 || r.GetLast() != 310 {
+		t.Errorf("adjoining ranges not merged: %v", applied[0])
+	}
+
+	s.removeRange(250, 259)
+	if len(applied[0].Range) != 3 {
+		t.Fatalf("range not split on remove: %v", applied[0])
+	}
+	if r := applied[0].Range[2]; r.GetFirst() != 260 || r.GetLast() != 310 {
+		t.Errorf("incorrect range after remove: %v", applied[0])
+	}
+
+	s.addAll(0)
+	if !applied[0].GetAllChannels() || len(applied[0].Range) != 0 {
+		t.Errorf("incorrect wildcard subscription: %v", applied[0])
+	}
+	s.removeAll()
+	if len(applied) != 0 {
+		t.Errorf("subscriptions remain after removeAll: %v", applied)
+	}
+}
+
+// Subscribe to channels on a server which rejects one of them, and
+// verify the acknowledgment returned by SubscribeAndWait.
+func TestSubscribeAndWait(t *testing.T) {
+	srvLink := rawlink.NewLink()
+	defer srvLink.Close()
+	srvLink.SubscriptionFunc = func(c *rawlink.Conn, subs []*sielink.Subscription) (accepted, rejected []*sielink.Subscription) {
+		for _, s := range subs {
+			acc := &sielink.Subscription{SourceSite: s.SourceSite}
+			for _, ch := range s.Channel {
+				if ch == 13 {
+					rejected = append(rejected, &sielink.Subscription{Channel: []uint32{ch}})
+					continue
+				}
+				acc.Channel = append(acc.Channel, ch)
+			}
+			accepted = append(accepted, acc)
+		}
+		return
+	}
+	ts := httptest.NewServer(websocket.Handler(func(c *websocket.Conn) {
+		srvLink.HandleConnection(c)
+	}))
+	defer ts.Close()
+
+	cl := NewClient(&Config{URL: "http://localhost/TestSubscribeAndWait"})
+	defer cl.Close()
+	go cl.DialAndHandle(strings.Replace(ts.URL, "http://", "ws://", 1))
+	<-cl.Ready()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ack, err := cl.SubscribeAndWait(ctx, 12, 13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ack.GetAccepted()) != 1 || len(ack.Accepted[0].Channel) != 1 ||
+		ack.Accepted[0].Channel[0] != 12 {
+		t.Errorf("incorrect accepted subscriptions: %v", ack)
+	}
+	if len(ack.GetRejected()) != 1 || ack.Rejected[0].Channel[0] != 13 {
+		t.Errorf("incorrect rejected subscriptions: %v", ack)
+	}
+}
@@ -1,10 +1,13 @@
 module github.com/farsightsec/sielink
 
-go 1.19
+go 1.21
 
 require (
 	github.com/golang/protobuf v1.5.3
 	golang.org/x/net v0.17.0
 )
 
-require google.golang.org/protobuf v1.31.0 // indirect
+require (
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
+)
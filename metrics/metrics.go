@@ -0,0 +1,389 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+// Package metrics implements the counters, gauges and histograms used to
+// instrument sielink Links, and exposes them in the Prometheus text
+// exposition format without depending on the Prometheus client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets are histogram bucket upper bounds, in seconds, suitable
+// for network latencies.
+var DefaultBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// A Registry holds a collection of metrics, and serves them over HTTP in
+// the Prometheus text format.
+type Registry struct {
+	mutex   sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.names[name] {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteTo writes all metrics in the registry to w in the Prometheus
+// text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mutex.Lock()
+	metrics := r.metrics
+	r.mutex.Unlock()
+
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// ServeHTTP serves the registry's metrics in the Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// desc holds the name, help and labels of a metric family, and its
+// series indexed by label values.
+type desc struct {
+	name, help, typ string
+	labels          []string
+
+	mutex  sync.RWMutex
+	series map[string]interface{}
+	newFn  func() interface{}
+}
+
+func newDesc(name, help, typ string, labels []string, newFn func() interface{}) *desc {
+	return &desc{
+		name:   name,
+		help:   help,
+		typ:    typ,
+		labels: labels,
+		series: make(map[string]interface{}),
+		newFn:  newFn,
+	}
+}
+
+func (d *desc) with(values []string) interface{} {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s requires %d label values, got %d",
+			d.name, len(d.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	d.mutex.RLock()
+	s, ok := d.series[key]
+	d.mutex.RUnlock()
+	if ok {
+		return s
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if s, ok = d.series[key]; !ok {
+		s = d.newFn()
+		d.series[key] = s
+	}
+	return s
+}
+
+// delete removes the series with the given label values, reporting
+// whether it existed.
+func (d *desc) delete(values []string) bool {
+	key := strings.Join(values, "\xff")
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	_, ok := d.series[key]
+	delete(d.series, key)
+	return ok
+}
+
+// each calls f with the label string and value of each series, in order
+// of label values.
+func (d *desc) each(w *bufio.Writer, f func(labels string, s interface{})) {
+	d.mutex.RLock()
+	keys := make([]string, 0, len(d.series))
+	series := make(map[string]interface{}, len(d.series))
+	for k, s := range d.series {
+		keys = append(keys, k)
+		series[k] = s
+	}
+	d.mutex.RUnlock()
+	sort.Strings(keys)
+
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.typ)
+	for _, k := range keys {
+		var values []string
+		if len(d.labels) > 0 {
+			values = strings.Split(k, "\xff")
+		}
+		f(formatLabels(d.labels, values), series[k])
+	}
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, n := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(n)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// addLabel returns labels with an additional label appended.
+func addLabel(labels, name, value string) string {
+	l := name + `="` + value + `"`
+	if labels == "" {
+		return "{" + l + "}"
+	}
+	return labels[:len(labels)-1] + "," + l + "}"
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// value is a float64 updated atomically.
+type value struct{ bits uint64 }
+
+func (v *value) add(d float64) {
+	for {
+		old := atomic.LoadUint64(&v.bits)
+		nv := math.Float64bits(math.Float64frombits(old) + d)
+		if atomic.CompareAndSwapUint64(&v.bits, old, nv) {
+			return
+		}
+	}
+}
+
+func (v *value) set(f float64) { atomic.StoreUint64(&v.bits, math.Float64bits(f)) }
+func (v *value) get() float64  { return math.Float64frombits(atomic.LoadUint64(&v.bits)) }
+
+// A Counter is a monotonically increasing value.
+type Counter struct{ v value }
+
+// Inc increments the counter by 1.
+func (c *Counter) Inc() { c.v.add(1) }
+
+// Add increases the counter by d, which must not be negative.
+func (c *Counter) Add(d float64) {
+	if d < 0 {
+		panic("metrics: counter decreased")
+	}
+	c.v.add(d)
+}
+
+// Value returns the current value of the counter.
+func (c *Counter) Value() float64 { return c.v.get() }
+
+// A CounterVec is a family of Counters distinguished by label values.
+type CounterVec struct{ d *desc }
+
+// NewCounterVec registers and returns a new CounterVec with the given
+// label names.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{newDesc(name, help, "counter", labels,
+		func() interface{} { return new(Counter) })}
+	r.register(name, v)
+	return v
+}
+
+// With returns the Counter with the given label values, creating it if
+// necessary.
+func (v *CounterVec) With(values ...string) *Counter {
+	return v.d.with(values).(*Counter)
+}
+
+// Delete removes the Counter with the given label values, so that it is
+// no longer exported. It reports whether the Counter existed.
+func (v *CounterVec) Delete(values ...string) bool {
+	return v.d.delete(values)
+}
+
+func (v *CounterVec) write(w *bufio.Writer) {
+	v.d.each(w, func(labels string, s interface{}) {
+		fmt.Fprintf(w, "%s%s %s\n", v.d.name, labels, formatFloat(s.(*Counter).Value()))
+	})
+}
+
+// A Gauge is a value which may increase or decrease.
+type Gauge struct{ v value }
+
+// Set sets the gauge to f.
+func (g *Gauge) Set(f float64) { g.v.set(f) }
+
+// Add adds d to the gauge.
+func (g *Gauge) Add(d float64) { g.v.add(d) }
+
+// Inc increments the gauge by 1.
+func (g *Gauge) Inc() { g.v.add(1) }
+
+// Dec decrements the gauge by 1.
+func (g *Gauge) Dec() { g.v.add(-1) }
+
+// Value returns the current value of the gauge.
+func (g *Gauge) Value() float64 { return g.v.get() }
+
+// A GaugeVec is a family of Gauges distinguished by label values.
+type GaugeVec struct{ d *desc }
+
+// NewGaugeVec registers and returns a new GaugeVec with the given label
+// names.
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	v := &GaugeVec{newDesc(name, help, "gauge", labels,
+		func() interface{} { return new(Gauge) })}
+	r.register(name, v)
+	return v
+}
+
+// With returns the Gauge with the given label values, creating it if
+// necessary.
+func (v *GaugeVec) With(values ...string) *Gauge {
+	return v.d.with(values).(*Gauge)
+}
+
+// Delete removes the Gauge with the given label values, so that it is
+// no longer exported. It reports whether the Gauge existed.
+func (v *GaugeVec) Delete(values ...string) bool {
+	return v.d.delete(values)
+}
+
+func (v *GaugeVec) write(w *bufio.Writer) {
+	v.d.each(w, func(labels string, s interface{}) {
+		fmt.Fprintf(w, "%s%s %s\n", v.d.name, labels, formatFloat(s.(*Gauge).Value()))
+	})
+}
+
+// A Histogram counts observations in configurable buckets.
+type Histogram struct {
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     value
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+// Observe records the observation f.
+func (h *Histogram) Observe(f float64) {
+	i := sort.SearchFloat64s(h.buckets, f)
+	if i < len(h.counts) {
+		atomic.AddUint64(&h.counts[i], 1)
+	}
+	h.sum.add(f)
+	atomic.AddUint64(&h.count, 1)
+}
+
+// Count returns the number of observations recorded.
+func (h *Histogram) Count() uint64 { return atomic.LoadUint64(&h.count) }
+
+// A HistogramVec is a family of Histograms distinguished by label values.
+type HistogramVec struct{ d *desc }
+
+// NewHistogramVec registers and returns a new HistogramVec with the given
+// bucket upper bounds, which must be sorted, and label names.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	v := &HistogramVec{newDesc(name, help, "histogram", labels,
+		func() interface{} { return newHistogram(buckets) })}
+	r.register(name, v)
+	return v
+}
+
+// With returns the Histogram with the given label values, creating it if
+// necessary.
+func (v *HistogramVec) With(values ...string) *Histogram {
+	return v.d.with(values).(*Histogram)
+}
+
+// Delete removes the Histogram with the given label values, so that it is
+// no longer exported. It reports whether the Histogram existed.
+func (v *HistogramVec) Delete(values ...string) bool {
+	return v.d.delete(values)
+}
+
+func (v *HistogramVec) write(w *bufio.Writer) {
+	v.d.each(w, func(labels string, s interface{}) {
+		h := s.(*Histogram)
+		var cum uint64
+		for i, b := range h.buckets {
+			cum += atomic.LoadUint64(&h.counts[i])
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.d.name,
+				addLabel(labels, "le", formatFloat(b)), cum)
+		}
+		count := h.Count()
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.d.name, addLabel(labels, "le", "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.d.name, labels, formatFloat(h.sum.get()))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.d.name, labels, count)
+	})
+}
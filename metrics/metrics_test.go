@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_total", "A test counter.", "channel")
+	c.With("5").Add(3)
+	c.With("10").Inc()
+	g := r.NewGaugeVec("test_gauge", "A test\ngauge.", "state")
+	g.With(`a"b`).Set(2)
+	g.With(`a"b`).Dec()
+	h := r.NewHistogramVec("test_seconds", "A test histogram.", []float64{.1, 1})
+	h.With().Observe(.05)
+	h.With().Observe(.5)
+	h.With().Observe(5)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	expected := `# HELP test_total A test counter.
+# TYPE test_total counter
+test_total{channel="10"} 1
+test_total{channel="5"} 3
+# HELP test_gauge A test\ngauge.
+# TYPE test_gauge gauge
+test_gauge{state="a\"b"} 1
+# HELP test_seconds A test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.1"} 1
+test_seconds_bucket{le="1"} 2
+test_seconds_bucket{le="+Inf"} 3
+test_seconds_sum 5.55
+test_seconds_count 3
+`
+	if got := w.Body.String(); got != expected {
+		t.Errorf("incorrect exposition:\n%s\nexpected:\n%s", got, expected)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("incorrect content type %q", ct)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGaugeVec("test_gauge", "A test gauge.", "peer")
+	g.With("a").Set(1)
+	g.With("b").Set(2)
+	h := r.NewHistogramVec("test_seconds", "A test histogram.", []float64{1}, "peer")
+	h.With("a").Observe(.5)
+	if !g.Delete("a") || !h.Delete("a") {
+		t.Error("Delete did not find series")
+	}
+	if g.Delete("a") {
+		t.Error("Delete found deleted series")
+	}
+
+	var b strings.Builder
+	r.WriteTo(&b)
+	if strings.Contains(b.String(), `peer="a"`) {
+		t.Errorf("deleted series exported:\n%s", b.String())
+	}
+	if !strings.Contains(b.String(), `test_gauge{peer="b"} 2`) {
+		t.Errorf("remaining series not exported:\n%s", b.String())
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("duplicate registration did not panic")
+		}
+	}()
+	r := NewRegistry()
+	r.NewCounterVec("test_total", "")
+	r.NewGaugeVec("test_total", "")
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package sielink
+
+import (
+	"fmt"
+	"sync"
+)
+
+// A Handler processes payloads dispatched by a PayloadMux.
+type Handler interface {
+	HandlePayload(*Payload)
+}
+
+// The HandlerFunc type allows an ordinary function to be used as a
+// Handler.
+type HandlerFunc func(*Payload)
+
+// HandlePayload calls f(p).
+func (f HandlerFunc) HandlePayload(p *Payload) {
+	f(p)
+}
+
+type muxKey struct {
+	ptype   PayloadType
+	channel uint32
+}
+
+// PayloadMux dispatches payloads to handlers registered for their
+// payload type and channel. A payload is handled by the most specific
+// handler registered for it, in order of preference:
+//
+//	a handler registered with Handle for its type and channel,
+//	a handler registered with HandleChannel for its channel,
+//	a handler registered with HandleType for its type,
+//	the handler registered with HandleDefault.
+//
+// Payloads with no matching handler are discarded.
+type PayloadMux struct {
+	mutex    sync.RWMutex
+	handlers map[muxKey]Handler
+	channels map[uint32]Handler
+	types    map[PayloadType]Handler
+	fallback Handler
+
+	// PanicFunc, if set, is called with the payload and recovered
+	// value when a handler panics. The panic is otherwise discarded,
+	// and the PayloadMux continues dispatching payloads.
+	PanicFunc func(p *Payload, r interface{})
+}
+
+// NewPayloadMux returns an empty PayloadMux.
+func NewPayloadMux() *PayloadMux {
+	return &PayloadMux{
+		handlers: make(map[muxKey]Handler),
+		channels: make(map[uint32]Handler),
+		types:    make(map[PayloadType]Handler),
+	}
+}
+
+// Handle registers the handler for payloads of type t on the given channel.
+// It panics if a handler is already registered for them.
+func (m *PayloadMux) Handle(t PayloadType, channel uint32, h Handler) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	k := muxKey{t, channel}
+	if _, ok := m.handlers[k]; ok {
+		panic(fmt.Sprintf("sielink: multiple handlers for %s on channel %d", t, channel))
+	}
+	m.handlers[k] = h
+}
+
+// HandleFunc registers the handler function for payloads of type t on
+// the given channel.
+func (m *PayloadMux) HandleFunc(t PayloadType, channel uint32, f func(*Payload)) {
+	m.Handle(t, channel, HandlerFunc(f))
+}
+
+// HandleChannel registers the handler for payloads of any type on the
+// given channel. It panics if a handler is already registered for the
+// channel.
+func (m *PayloadMux) HandleChannel(channel uint32, h Handler) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.channels[channel]; ok {
+		panic(fmt.Sprintf("sielink: multiple handlers for channel %d", channel))
+	}
+	m.channels[channel] = h
+}
+
+// HandleType registers the handler for payloads of type t on any channel.
+// It panics if a handler is already registered for the type.
+func (m *PayloadMux) HandleType(t PayloadType, h Handler) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.types[t]; ok {
+		panic(fmt.Sprintf("sielink: multiple handlers for %s", t))
+	}
+	m.types[t] = h
+}
+
+// HandleDefault registers the handler for payloads matching no other
+// handler.
+func (m *PayloadMux) HandleDefault(h Handler) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.fallback = h
+}
+
+// Handler returns the handler which will process p, or nil if there is none.
+func (m *PayloadMux) Handler(p *Payload) Handler {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	t, ch := p.GetPayloadType(), p.GetChannel()
+	if h, ok := m.handlers[muxKey{t, ch}]; ok {
+		return h
+	}
+	if h, ok := m.channels[ch]; ok {
+		return h
+	}
+	if h, ok := m.types[t]; ok {
+		return h
+	}
+	return m.fallback
+}
+
+// HandlePayload dispatches p to its handler, recovering from any panic
+// in the handler.
+func (m *PayloadMux) HandlePayload(p *Payload) {
+	h := m.Handler(p)
+	if h == nil {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil && m.PanicFunc != nil {
+			m.PanicFunc(p, r)
+		}
+	}()
+	h.HandlePayload(p)
+}
+
+// Serve dispatches payloads received on the Link from the given number
+// of worker goroutines, returning when the Link's Receive channel is
+// closed and all workers have finished. Payloads are handled in order
+// of receipt only if workers is 1 or less.
+func (m *PayloadMux) Serve(l Link, workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+	recv := l.Receive()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range recv {
+				m.HandlePayload(p)
+			}
+		}()
+	}
+	wg.Wait()
+}
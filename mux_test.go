@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package sielink
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+type testLink chan *Payload
+
+func (l testLink) Send(p *Payload) error    { l <- p; return nil }
+func (l testLink) Receive() <-chan *Payload { return l }
+func (l testLink) Close() error             { close(l); return nil }
+
+func TestPayloadMux(t *testing.T) {
+	var mutex sync.Mutex
+	handled := make(map[string]int)
+	handler := func(name string) Handler {
+		return HandlerFunc(func(p *Payload) {
+			mutex.Lock()
+			handled[name]++
+			mutex.Unlock()
+		})
+	}
+
+	m := NewPayloadMux()
+	m.Handle(PayloadType_NmsgContainer, 5, handler("type+channel"))
+	m.HandleChannel(5, handler("channel"))
+	m.HandleType(PayloadType_NmsgContainer, handler("type"))
+	m.HandleDefault(handler("default"))
+	m.HandleFunc(PayloadType_LogRecord, 6, func(p *Payload) { panic("handler panic") })
+	panics := 0
+	m.PanicFunc = func(p *Payload, r interface{}) {
+		mutex.Lock()
+		panics++
+		mutex.Unlock()
+	}
+
+	payload := func(t PayloadType, ch uint32) *Payload {
+		return &Payload{PayloadType: t.Enum(), Channel: proto.Uint32(ch)}
+	}
+	l := make(testLink, 10)
+	l.Send(payload(PayloadType_NmsgContainer, 5))
+	l.Send(payload(PayloadType_LogRecord, 5))
+	l.Send(payload(PayloadType_NmsgContainer, 7))
+	l.Send(payload(PayloadType_LogRecord, 7))
+	l.Send(payload(PayloadType_LogRecord, 6))
+	l.Send(payload(PayloadType_LogRecord, 8))
+	l.Close()
+	m.Serve(l, 3)
+
+	for name, n := range map[string]int{
+		"type+channel": 1, "channel": 1, "type": 1, "default": 2,
+	} {
+		if handled[name] != n {
+			t.Errorf("%s handler called %d times, expected %d", name, handled[name], n)
+		}
+	}
+	if panics != 1 {
+		t.Errorf("PanicFunc called %d times, expected 1", panics)
+	}
+}
+
+func TestPayloadMuxDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("duplicate registration did not panic")
+		}
+	}()
+	m := NewPayloadMux()
+	m.HandleType(PayloadType_LogRecord, HandlerFunc(func(*Payload) {}))
+	m.HandleType(PayloadType_LogRecord, HandlerFunc(func(*Payload) {}))
+}
@@ -0,0 +1,187 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package rawlink
+
+import (
+	"sync"
+	"time"
+
+	"github.com/farsightsec/sielink"
+)
+
+// alertBuffer is the number of AlertEvents buffered for each watcher.
+// Events are discarded for watchers which fall this far behind.
+const alertBuffer = 64
+
+// An AlertEvent reports an alert received from a peer.
+type AlertEvent struct {
+	Conn  *Conn
+	Alert *sielink.Alert
+	Time  time.Time
+	// Suppressed is the number of alerts with the same code received
+	// on the connection since the previous event for that code, and
+	// not delivered because they arrived within the Link's
+	// AlertInterval. If alerts were suppressed, the most recent of them
+	// is delivered once AlertInterval has passed or the connection
+	// closes, whichever is sooner.
+	Suppressed int
+}
+
+// alertLimit tracks the delivery of alerts with one code on a connection.
+// While alerts are being suppressed, alert holds the most recent of them,
+// and timer is set to deliver it at the end of the interval.
+type alertLimit struct {
+	last       time.Time
+	suppressed int
+	alert      *sielink.Alert
+	timer      *time.Timer
+}
+
+// limitAlert reports whether an alert received on c should be delivered,
+// and if so, how many with the same code were suppressed since the
+// previous delivery.
+func (l *Link) limitAlert(c *Conn, alert *sielink.Alert) (deliver bool, suppressed int) {
+	interval := l.AlertInterval
+	if interval <= 0 {
+		return true, 0
+	}
+	code := alert.GetCode()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.alertLimits == nil {
+		c.alertLimits = make(map[uint32]*alertLimit)
+	}
+	lim, ok := c.alertLimits[code]
+	if !ok {
+		lim = new(alertLimit)
+		c.alertLimits[code] = lim
+	}
+	now := time.Now()
+	if now.Sub(lim.last) < interval {
+		lim.suppressed++
+		lim.alert = alert
+		if lim.timer == nil {
+			lim.timer = time.AfterFunc(lim.last.Add(interval).Sub(now),
+				func() { l.flushAlert(c, code) })
+		}
+		return false, 0
+	}
+	suppressed = lim.suppressed
+	lim.reset(now)
+	return true, suppressed
+}
+
+// reset records a delivery at time t, discarding any pending alert.
+func (lim *alertLimit) reset(t time.Time) {
+	if lim.timer != nil {
+		lim.timer.Stop()
+	}
+	lim.last, lim.suppressed, lim.alert, lim.timer = t, 0, nil, nil
+}
+
+// flushAlert delivers the most recent alert with the given code
+// suppressed on c, if any, with the number suppressed before it.
+func (l *Link) flushAlert(c *Conn, code uint32) {
+	c.mutex.Lock()
+	lim := c.alertLimits[code]
+	if lim == nil || lim.alert == nil {
+		c.mutex.Unlock()
+		return
+	}
+	alert, suppressed := lim.alert, lim.suppressed-1
+	lim.reset(time.Now())
+	c.mutex.Unlock()
+	l.deliverAlert(c, alert, suppressed)
+}
+
+// flushAlerts delivers the alerts pending on c for all codes. It is
+// called when the connection closes.
+func (l *Link) flushAlerts(c *Conn) {
+	c.mutex.Lock()
+	codes := make([]uint32, 0, len(c.alertLimits))
+	for code := range c.alertLimits {
+		codes = append(codes, code)
+	}
+	c.mutex.Unlock()
+	for _, code := range codes {
+		l.flushAlert(c, code)
+	}
+}
+
+type alertWatchers struct {
+	mutex    sync.Mutex
+	watchers map[chan AlertEvent]struct{}
+}
+
+func (w *alertWatchers) send(ev AlertEvent) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for ch := range w.watchers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// WatchAlerts returns a channel delivering an AlertEvent for each alert
+// received from a peer, and a function which ends delivery and closes
+// the channel. Non-fatal alerts repeated with the same code on the same
+// connection within AlertInterval are counted rather than delivered.
+// Events are discarded if the channel's buffer is full, so a slow
+// watcher never delays reading from connections.
+func (l *Link) WatchAlerts() (<-chan AlertEvent, func()) {
+	ch := make(chan AlertEvent, alertBuffer)
+	w := &l.alertWatchers
+	w.mutex.Lock()
+	if w.watchers == nil {
+		w.watchers = make(map[chan AlertEvent]struct{})
+	}
+	w.watchers[ch] = struct{}{}
+	w.mutex.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			w.mutex.Lock()
+			delete(w.watchers, ch)
+			w.mutex.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// receiveAlert handles an alert received on c, returning an error if
+// the alert is fatal. Non-fatal alerts which are not suppressed by
+// AlertInterval are passed to l.AlertFunc.
+func (l *Link) receiveAlert(c *Conn, alert *sielink.Alert) error {
+	l.metrics.alert("received", alert.GetLevel())
+	if alert.GetLevel() == sielink.AlertLevel_FatalError {
+		l.deliverAlert(c, alert, 0)
+		return &RemoteAlertError{alert}
+	}
+	deliver, suppressed := l.limitAlert(c, alert)
+	if !deliver {
+		return nil
+	}
+	l.deliverAlert(c, alert, suppressed)
+	l.AlertFunc(c, alert)
+	return nil
+}
+
+// deliverAlert logs an alert received on c and sends it to the alert
+// watchers.
+func (l *Link) deliverAlert(c *Conn, alert *sielink.Alert, suppressed int) {
+	l.logAlert(c, "received", alert, "suppressed", suppressed)
+	l.alertWatchers.send(AlertEvent{
+		Conn:       c,
+		Alert:      alert,
+		Time:       time.Now(),
+		Suppressed: suppressed,
+	})
+}
@@ -8,21 +8,7 @@
 
 package rawlink
 
-import (
-	"github.com/golang/protobuf/proto"
-	"github.com/farsightsec/sielink"
-)
-
-func (l *Link) setHeartbeat(hbtime uint32) {
-	l.mutex.Lock()
-	defer l.mutex.Unlock()
-
-	l.configMessage = newConfigMessage(
-		l.configMessage.Topology.Subscription,
-		l.configMessage.Topology.Path,
-		proto.Uint32(hbtime),
-	)
-}
+import "github.com/farsightsec/sielink"
 
 func (l *Link) linkConfigMessage() (m *sielink.Message, ch <-chan struct{}) {
 	l.mutex.Lock()
@@ -30,14 +16,15 @@ func (l *Link) linkConfigMessage() (m *sielink.Message, ch <-chan struct{}) {
 	return l.configMessage, l.configUpdate
 }
 
-func newConfigMessage(subs []*sielink.Subscription, paths []*sielink.Path, hb *uint32) *sielink.Message {
+func newConfigMessage(subs []*sielink.Subscription, serial *uint32, paths []*sielink.Path, hb *uint32) *sielink.Message {
 	return &sielink.Message{
 		ProtocolVersion: sielink.SupportedVersions,
 		MessageType:     sielink.MessageType_TopologyMessage.Enum(),
 		Heartbeat:       hb,
 		Topology: &sielink.Topology{
-			Subscription: subs,
-			Path:         paths,
+			Subscription:       subs,
+			SubscriptionSerial: serial,
+			Path:               paths,
 		},
 	}
 }
@@ -0,0 +1,353 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package rawlink
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/farsightsec/sielink"
+)
+
+// A Conn is a connection handled by a Link. The same Conn is passed to
+// all Link callbacks for the lifetime of the connection, and may be used
+// to identify it.
+type Conn struct {
+	// The counters are first to ensure 64-bit alignment for atomic
+	// operations on 32-bit platforms.
+	payloadsSent, payloadsReceived uint64
+	bytesSent, bytesReceived       uint64
+
+	ws        *websocket.Conn
+	connected time.Time
+	principal string
+
+	// metricsPeer labels the connection's peer in metrics. It is fixed
+	// when the connection is accepted, so that the series it is added
+	// to are the ones removed when it closes.
+	metricsPeer string
+
+	mutex         sync.Mutex
+	state         ConnState
+	peerState     PeerState
+	version       uint32
+	heartbeat     time.Duration
+	lastHeartbeat time.Time
+	topology      *sielink.Topology
+	ackedSerial   uint32
+	alertLimits   map[uint32]*alertLimit
+
+	// localHeartbeat and requestedHeartbeat are the intervals requested
+	// by this Link and by the peer, from which the negotiated interval
+	// is derived.
+	localHeartbeat, requestedHeartbeat time.Duration
+	hbUpdate                           chan struct{}
+	done                               chan struct{}
+
+	// peerTimestamp is the most recent timestamp received from the
+	// peer and not yet echoed, and peerTimestampAt the time of its
+	// receipt.
+	peerTimestamp   uint64
+	peerTimestampAt time.Time
+	rtt             time.Duration
+	clockOffset     time.Duration
+	clockSkewed     bool
+}
+
+// PeerState describes what a connection's peer has announced about
+// its intentions.
+type PeerState int
+
+const (
+	// PeerNormal peers are sending and receiving data normally.
+	PeerNormal PeerState = iota
+	// PeerShutdown peers have requested that the Link finish sending
+	// on the connection.
+	PeerShutdown
+	// PeerFinished peers have finished sending on the connection.
+	PeerFinished
+)
+
+var peerStateNames = map[PeerState]string{
+	PeerNormal:   "normal",
+	PeerShutdown: "remote-shutdown",
+	PeerFinished: "remote-finished",
+}
+
+func (s PeerState) String() string {
+	if name, ok := peerStateNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ConnStats holds the counters of payloads and payload data bytes sent
+// and received on a connection.
+type ConnStats struct {
+	PayloadsSent, PayloadsReceived uint64
+	BytesSent, BytesReceived       uint64
+}
+
+func newConn(ws *websocket.Conn) *Conn {
+	c := &Conn{
+		ws:        ws,
+		connected: time.Now(),
+		principal: tlsPrincipal(ws),
+		hbUpdate:  make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	c.metricsPeer = c.peerLabel()
+	return c
+}
+
+// tlsPrincipal returns the common name of the verified client certificate
+// presented to a server connection, if any.
+func tlsPrincipal(ws *websocket.Conn) string {
+	if !ws.IsServerConn() {
+		return ""
+	}
+	req := ws.Request()
+	if req == nil || req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+		return ""
+	}
+	return req.TLS.VerifiedChains[0][0].Subject.CommonName
+}
+
+// WebSocket returns the underlying websocket connection.
+func (c *Conn) WebSocket() *websocket.Conn {
+	return c.ws
+}
+
+// RemoteAddr returns the address of the peer. For server connections,
+// this is the address of the HTTP client. For client connections, it is
+// the URL of the server.
+func (c *Conn) RemoteAddr() string {
+	if c.ws.IsServerConn() {
+		if req := c.ws.Request(); req != nil {
+			return req.RemoteAddr
+		}
+	}
+	return c.ws.RemoteAddr().String()
+}
+
+// Principal returns the authenticated identity of the peer: the common
+// name of its verified TLS client certificate, or the principal set with
+// SetPrincipal.
+func (c *Conn) Principal() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.principal
+}
+
+// SetPrincipal records the identity of a peer authenticated by means
+// other than a TLS client certificate.
+func (c *Conn) SetPrincipal(p string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.principal = p
+}
+
+// Done returns a channel which is closed when the connection ends.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Connected returns the time at which the Link began handling the
+// connection.
+func (c *Conn) Connected() time.Time {
+	return c.connected
+}
+
+// Version returns the protocol version negotiated with the peer, or zero
+// if the handshake has not completed.
+func (c *Conn) Version() uint32 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.version
+}
+
+// Heartbeat returns the heartbeat interval most recently announced by
+// the peer, or zero if the peer does not send heartbeats.
+func (c *Conn) Heartbeat() time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.heartbeat
+}
+
+// Topology returns the latest topology received from the peer.
+func (c *Conn) Topology() *sielink.Topology {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.topology
+}
+
+// Stats returns the connection's payload counters.
+func (c *Conn) Stats() ConnStats {
+	return ConnStats{
+		PayloadsSent:     atomic.LoadUint64(&c.payloadsSent),
+		PayloadsReceived: atomic.LoadUint64(&c.payloadsReceived),
+		BytesSent:        atomic.LoadUint64(&c.bytesSent),
+		BytesReceived:    atomic.LoadUint64(&c.bytesReceived),
+	}
+}
+
+// ConnInfo is a snapshot of the state of a connection, as returned by
+// Link.Connections.
+type ConnInfo struct {
+	Conn       *Conn
+	RemoteAddr string
+	Principal  string
+	State      ConnState
+	PeerState  PeerState
+	Version    uint32
+	Connected  time.Time
+
+	// Heartbeat is the heartbeat interval announced by the peer, and
+	// LastHeartbeat the time its most recent heartbeat was received.
+	// NegotiatedHeartbeat is the interval agreed with the peer.
+	Heartbeat           time.Duration
+	LastHeartbeat       time.Time
+	NegotiatedHeartbeat time.Duration
+
+	// RTT and ClockOffset are the smoothed round-trip time of the
+	// connection and offset of the peer's clock, as measured through
+	// heartbeats. Both are zero until the first measurement.
+	// ClockSkewed reports whether ClockOffset exceeds the Link's
+	// ClockSkewThreshold.
+	RTT         time.Duration
+	ClockOffset time.Duration
+	ClockSkewed bool
+
+	// Subscription and Path are those most recently announced by
+	// the peer.
+	Subscription []*sielink.Subscription
+	Path         []*sielink.Path
+
+	ConnStats
+}
+
+// Connections returns a snapshot of the connections handled by the Link,
+// in the order they were connected.
+func (l *Link) Connections() []ConnInfo {
+	conns := l.connList()
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].connected.Before(conns[j].connected)
+	})
+
+	info := make([]ConnInfo, len(conns))
+	for i, c := range conns {
+		info[i] = c.info()
+	}
+	return info
+}
+
+// connList returns the connections handled by the Link.
+func (l *Link) connList() []*Conn {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	conns := make([]*Conn, 0, len(l.conns))
+	for c := range l.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+func (c *Conn) info() ConnInfo {
+	stats := c.Stats()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return ConnInfo{
+		Conn:          c,
+		RemoteAddr:    c.RemoteAddr(),
+		Principal:     c.principal,
+		State:         c.state,
+		PeerState:     c.peerState,
+		Version:       c.version,
+		Connected:     c.connected,
+		Heartbeat:     c.heartbeat,
+		LastHeartbeat: c.lastHeartbeat,
+		Subscription:  c.topology.GetSubscription(),
+		Path:          c.topology.GetPath(),
+		ConnStats:     stats,
+
+		NegotiatedHeartbeat: negotiateHeartbeat(c.localHeartbeat, c.requestedHeartbeat),
+		RTT:                 c.rtt,
+		ClockOffset:         c.clockOffset,
+		ClockSkewed:         c.clockSkewed,
+	}
+}
+
+// setState records the connection's new state and reports it to
+// l.StateFunc.
+func (l *Link) setState(c *Conn, s ConnState, err error) {
+	c.mutex.Lock()
+	prev := c.state
+	c.state = s
+	c.mutex.Unlock()
+	l.metrics.stateChange(prev, s)
+	l.StateFunc(c, s, err)
+}
+
+func (c *Conn) setPeerState(s PeerState) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.peerState = s
+}
+
+// receivedHeartbeat records the receipt of a heartbeat, and returns the
+// time by which it exceeded the announced heartbeat interval.
+func (c *Conn) receivedHeartbeat() (lateness time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	if !c.lastHeartbeat.IsZero() && c.heartbeat > 0 {
+		lateness = now.Sub(c.lastHeartbeat) - c.heartbeat
+	}
+	c.lastHeartbeat = now
+	return lateness
+}
+
+func (c *Conn) setHeartbeat(ms uint32) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.heartbeat = time.Duration(ms) * time.Millisecond
+}
+
+func (c *Conn) setVersion(v uint32) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.version = v
+}
+
+// setTopology records t as the latest topology, and reports whether it
+// carries a subscription serial not previously seen.
+func (c *Conn) setTopology(t *sielink.Topology) (newSerial bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.topology = t
+	if serial := t.GetSubscriptionSerial(); serial != c.ackedSerial {
+		c.ackedSerial = serial
+		return true
+	}
+	return false
+}
+
+func (c *Conn) countSent(p *sielink.Payload) {
+	atomic.AddUint64(&c.payloadsSent, 1)
+	atomic.AddUint64(&c.bytesSent, uint64(len(p.Data)))
+}
+
+func (c *Conn) countReceived(p *sielink.Payload) {
+	atomic.AddUint64(&c.payloadsReceived, 1)
+	atomic.AddUint64(&c.bytesReceived, uint64(len(p.Data)))
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package rawlink
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Drain shuts the Link down gracefully. It sends Shutdown to its peers,
+// continues sending the payloads of pending Send calls until none remain,
+// then sends Finished and waits for all connections to end. If ctx is done
+// first, Drain closes the Link, and returns the number of payloads whose
+// Send calls had not completed, which are not sent, with the context's
+// error.
+func (l *Link) Drain(ctx context.Context) (unsent int, err error) {
+	l.Shutdown()
+	select {
+	case <-l.sendsIdle():
+	case <-ctx.Done():
+		return l.forceClose(), ctx.Err()
+	}
+	l.Finish()
+	if err = l.Wait(ctx); err != nil {
+		return l.forceClose(), err
+	}
+	return 0, nil
+}
+
+// forceClose closes the Link, and returns the number of Send calls which
+// were pending.
+func (l *Link) forceClose() int {
+	n := int(atomic.LoadInt32(&l.pendingSends))
+	l.Logger.Warn("drain deadline expired, closing link", "unsent", n)
+	l.Close()
+	return n
+}
+
+// beginSend and endSend count the Send calls in progress.
+func (l *Link) beginSend() {
+	atomic.AddInt32(&l.pendingSends, 1)
+}
+
+func (l *Link) endSend() {
+	if atomic.AddInt32(&l.pendingSends, -1) > 0 {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	// A Send may have begun since the count was decremented, and must
+	// be waited for.
+	if atomic.LoadInt32(&l.pendingSends) > 0 {
+		return
+	}
+	if l.idle != nil {
+		close(l.idle)
+		l.idle = nil
+	}
+}
+
+// sendsIdle returns a channel which is closed once no Send calls are in
+// progress.
+func (l *Link) sendsIdle() <-chan struct{} {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.idle == nil {
+		l.idle = make(chan struct{})
+	}
+	ch := l.idle
+	if atomic.LoadInt32(&l.pendingSends) == 0 {
+		close(l.idle)
+		l.idle = nil
+	}
+	return ch
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package rawlink
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/farsightsec/sielink"
+)
+
+// Errors returned by Link methods and HandleConnection, for use with
+// errors.Is.
+var (
+	// ErrLinkClosed is returned after Close has been called.
+	ErrLinkClosed = errors.New("Link is closed")
+	// ErrLinkShutdown is returned for new connections after Shutdown
+	// has been called.
+	ErrLinkShutdown = errors.New("Link is shut down")
+	// ErrLinkFinished is returned by Send after Finish has been called.
+	ErrLinkFinished = errors.New("Link is finished sending")
+
+	// ErrVersionMismatch matches errors reporting that the peers share
+	// no protocol version, whether detected locally or by the peer.
+	ErrVersionMismatch = errors.New("protocol version mismatch")
+	// ErrProtocolViolation matches errors reporting an unexpected or
+	// malformed message.
+	ErrProtocolViolation = errors.New("protocol violation")
+	// ErrAuthRejected matches fatal alerts from peers which did not
+	// accept the Link's credentials.
+	ErrAuthRejected = errors.New("authentication rejected")
+	// ErrHeartbeatTimeout matches errors ending connections on which
+	// the peer's heartbeats stopped arriving.
+	ErrHeartbeatTimeout = errors.New("heartbeat timeout")
+	// ErrPeerUnavailable matches fatal alerts from peers which are
+	// closed or shutting down, and not accepting connections.
+	ErrPeerUnavailable = errors.New("peer unavailable")
+)
+
+// A VersionError reports that a peer supports none of the local
+// protocol versions.
+type VersionError struct {
+	Remote, Supported []uint32
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("Versions %v not supported", e.Remote)
+}
+
+// Is reports whether target is ErrVersionMismatch.
+func (e *VersionError) Is(target error) bool {
+	return target == ErrVersionMismatch
+}
+
+// A ProtocolError reports a message which violates the protocol.
+type ProtocolError struct {
+	Message string
+}
+
+func (e *ProtocolError) Error() string {
+	return e.Message
+}
+
+// Is reports whether target is ErrProtocolViolation.
+func (e *ProtocolError) Is(target error) bool {
+	return target == ErrProtocolViolation
+}
+
+// A RemoteAlertError reports a fatal alert received from a peer. It
+// unwraps to the Alert, and matches the sentinel error corresponding
+// to the alert's code.
+type RemoteAlertError struct {
+	Alert *sielink.Alert
+}
+
+func (e *RemoteAlertError) Error() string {
+	return e.Alert.Error()
+}
+
+// Unwrap returns the Alert.
+func (e *RemoteAlertError) Unwrap() error {
+	return e.Alert
+}
+
+// Code returns the alert's code.
+func (e *RemoteAlertError) Code() uint32 {
+	return e.Alert.GetCode()
+}
+
+// Is reports whether target is the sentinel error corresponding to the
+// alert's code.
+func (e *RemoteAlertError) Is(target error) bool {
+	switch e.Code() {
+	case sielink.AlertCodeVersionMismatch:
+		return target == ErrVersionMismatch
+	case sielink.AlertCodeProtocolViolation:
+		return target == ErrProtocolViolation
+	case sielink.AlertCodeAuthRejected:
+		return target == ErrAuthRejected
+	case sielink.AlertCodeHeartbeatTimeout:
+		return target == ErrHeartbeatTimeout
+	case sielink.AlertCodeUnavailable:
+		return target == ErrPeerUnavailable
+	}
+	return false
+}
+
+// A PanicError reports a panic recovered while reading a connection.
+type PanicError struct {
+	Value interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// alertCode returns the alert code reporting err to a peer.
+func alertCode(err error) uint32 {
+	switch {
+	case errors.Is(err, ErrVersionMismatch):
+		return sielink.AlertCodeVersionMismatch
+	case errors.Is(err, ErrProtocolViolation):
+		return sielink.AlertCodeProtocolViolation
+	case errors.Is(err, ErrAuthRejected):
+		return sielink.AlertCodeAuthRejected
+	case errors.Is(err, ErrHeartbeatTimeout):
+		return sielink.AlertCodeHeartbeatTimeout
+	case errors.Is(err, ErrLinkClosed), errors.Is(err, ErrLinkShutdown),
+		errors.Is(err, ErrLinkFinished):
+		return sielink.AlertCodeUnavailable
+	}
+	return sielink.AlertCodeUnspecified
+}
+
+// A HeartbeatTimeoutError reports that no message arrived from the peer
+// within the heartbeat interval it announced.
+type HeartbeatTimeoutError struct {
+	Interval time.Duration
+	Err      error
+}
+
+func (e *HeartbeatTimeoutError) Error() string {
+	return fmt.Sprintf("no heartbeat received within %v", e.Interval)
+}
+
+// Is reports whether target is ErrHeartbeatTimeout.
+func (e *HeartbeatTimeoutError) Is(target error) bool {
+	return target == ErrHeartbeatTimeout
+}
+
+// Unwrap returns the read error reporting the expired deadline.
+func (e *HeartbeatTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// readError maps read deadline expiry, which the Link uses to detect
+// missing heartbeats, to a HeartbeatTimeoutError, and notifies the peer
+// before the connection is closed.
+func (l *Link) readError(c *Conn, err error) error {
+	var te interface{ Timeout() bool }
+	if !errors.As(err, &te) || !te.Timeout() {
+		return err
+	}
+	herr := &HeartbeatTimeoutError{Interval: c.Heartbeat(), Err: err}
+	c.ws.SetWriteDeadline(time.Now().Add(time.Second))
+	l.writeAlert(c, herr)
+	return herr
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package rawlink
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/farsightsec/sielink"
+)
+
+// Heartbeat intervals are negotiated per connection. Each peer requests
+// an interval in the heartbeat field of its topology messages, and both
+// peers send heartbeats at the shorter of the two requested intervals,
+// or at whichever is non-zero if only one peer requests heartbeats. Each
+// heartbeat message carries the negotiated interval, and its recipient
+// closes the connection if the next message does not arrive within one
+// and a half times that interval.
+
+// negotiateHeartbeat returns the heartbeat interval agreed by peers
+// requesting the supplied intervals.
+func negotiateHeartbeat(local, remote time.Duration) time.Duration {
+	if local == 0 || (remote != 0 && remote < local) {
+		return remote
+	}
+	return local
+}
+
+func heartbeatMillis(d time.Duration) uint32 {
+	return uint32(d / time.Millisecond)
+}
+
+// heartbeatDeadline returns the read deadline following a message
+// announcing the heartbeat interval d.
+func heartbeatDeadline(d time.Duration) time.Time {
+	return time.Now().Add(d + d/2)
+}
+
+// SetHeartbeat changes the heartbeat interval requested by the Link, and
+// renegotiates the interval on established connections.
+func (l *Link) SetHeartbeat(d time.Duration) {
+	l.mutex.Lock()
+	l.Heartbeat = d
+	l.setConfigHeartbeat(heartbeatMillis(d))
+	l.mutex.Unlock()
+	for _, c := range l.connList() {
+		c.setLocalHeartbeat(d)
+	}
+}
+
+// linkHeartbeat returns the heartbeat interval requested by the Link,
+// updating the configuration message if l.Heartbeat has been assigned
+// directly rather than through SetHeartbeat.
+func (l *Link) linkHeartbeat() time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if ms := heartbeatMillis(l.Heartbeat); ms != l.configMessage.GetHeartbeat() {
+		l.setConfigHeartbeat(ms)
+	}
+	return l.Heartbeat
+}
+
+// setConfigHeartbeat sets the heartbeat interval requested in the Link's
+// configuration message, and sends the updated message to all peers. It
+// must be called with l.mutex held.
+func (l *Link) setConfigHeartbeat(ms uint32) {
+	var hb *uint32
+	if ms > 0 {
+		hb = proto.Uint32(ms)
+	}
+	l.configMessage = newConfigMessage(
+		l.configMessage.Topology.Subscription,
+		l.configMessage.Topology.SubscriptionSerial,
+		l.configMessage.Topology.Path,
+		hb,
+	)
+	close(l.configUpdate)
+	l.configUpdate = make(chan struct{})
+}
+
+// NegotiatedHeartbeat returns the heartbeat interval agreed with the
+// peer, or zero if neither peer requests heartbeats.
+func (c *Conn) NegotiatedHeartbeat() time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return negotiateHeartbeat(c.localHeartbeat, c.requestedHeartbeat)
+}
+
+func (c *Conn) setLocalHeartbeat(d time.Duration) {
+	c.mutex.Lock()
+	changed := c.localHeartbeat != d
+	c.localHeartbeat = d
+	c.mutex.Unlock()
+	if changed {
+		c.notifyHeartbeat()
+	}
+}
+
+// setRequestedHeartbeat records the heartbeat interval requested in a
+// topology message from the peer.
+func (c *Conn) setRequestedHeartbeat(ms uint32) {
+	d := time.Duration(ms) * time.Millisecond
+	c.mutex.Lock()
+	changed := c.requestedHeartbeat != d
+	c.requestedHeartbeat = d
+	c.mutex.Unlock()
+	if changed {
+		c.notifyHeartbeat()
+	}
+}
+
+// notifyHeartbeat wakes the connection's heartbeat sender to apply a
+// renegotiated interval.
+func (c *Conn) notifyHeartbeat() {
+	select {
+	case c.hbUpdate <- struct{}{}:
+	default:
+	}
+}
+
+// sendHeartbeat sends heartbeats on c at the negotiated interval until
+// the connection ends.
+func (l *Link) sendHeartbeat(c *Conn) {
+	for {
+		var t *time.Timer
+		var next <-chan time.Time
+		if d := c.NegotiatedHeartbeat(); d > 0 {
+			c.ws.SetWriteDeadline(heartbeatDeadline(d))
+			m := &sielink.Message{
+				ProtocolVersion: sielink.SupportedVersions,
+				MessageType:     sielink.MessageType_Heartbeat.Enum(),
+				Heartbeat:       proto.Uint32(heartbeatMillis(d)),
+			}
+			c.stampHeartbeat(m)
+			if err := writeMessage(c.ws, m); err != nil {
+				return
+			}
+			t = time.NewTimer(d)
+			next = t.C
+		}
+		done := false
+		select {
+		case <-next:
+		case <-c.hbUpdate:
+		case <-c.done:
+			done = true
+		}
+		if t != nil {
+			t.Stop()
+		}
+		if done {
+			return
+		}
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package rawlink
+
+import (
+	"time"
+
+	"github.com/farsightsec/sielink"
+)
+
+// An Interceptor inspects a payload sent or received on connection c.
+// It returns the payload to pass on, which may be p, a modified p, or
+// a replacement payload. Returning a nil payload drops p. Returning an
+// error drops p and closes the connection with that error.
+type Interceptor func(c *Conn, p *sielink.Payload) (*sielink.Payload, error)
+
+// AddSendInterceptor appends i to the chain of interceptors called with
+// each payload before it is written to a connection. Interceptors are
+// called in the order they were added.
+func (l *Link) AddSendInterceptor(i Interceptor) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.sendInterceptors = appendInterceptor(l.sendInterceptors, i)
+}
+
+// AddReceiveInterceptor appends i to the chain of interceptors called
+// with each payload read from a connection, before it is offered to
+// ReceiveFunc or delivered to the Receive channel. Interceptors are
+// called in the order they were added.
+func (l *Link) AddReceiveInterceptor(i Interceptor) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.recvInterceptors = appendInterceptor(l.recvInterceptors, i)
+}
+
+// appendInterceptor returns a new chain, leaving the existing chain
+// unmodified for any connections running it.
+func appendInterceptor(chain []Interceptor, i Interceptor) []Interceptor {
+	nc := make([]Interceptor, len(chain), len(chain)+1)
+	copy(nc, chain)
+	return append(nc, i)
+}
+
+func (l *Link) interceptors(send bool) []Interceptor {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if send {
+		return l.sendInterceptors
+	}
+	return l.recvInterceptors
+}
+
+func runInterceptors(chain []Interceptor, c *Conn, p *sielink.Payload) (*sielink.Payload, error) {
+	var err error
+	for _, i := range chain {
+		if p, err = i(c, p); p == nil || err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// writePayload writes p to c after running the send interceptors.
+func (l *Link) writePayload(c *Conn, p *sielink.Payload) error {
+	p, err := runInterceptors(l.interceptors(true), c, p)
+	if p == nil {
+		if err == nil {
+			l.metrics.dropped("send")
+		}
+		return err
+	}
+	start := time.Now()
+	if err = writePayload(c.ws, p); err != nil {
+		return err
+	}
+	c.countSent(p)
+	l.metrics.sent(p, start)
+	return nil
+}
+
+// receivePayload delivers p after running the receive interceptors.
+func (l *Link) receivePayload(c *Conn, p *sielink.Payload) error {
+	c.countReceived(p)
+	l.metrics.received(p)
+	p, err := runInterceptors(l.interceptors(false), c, p)
+	if p == nil {
+		if err == nil {
+			l.metrics.dropped("receive")
+		}
+		return err
+	}
+	if l.ReceiveFunc(c, p) {
+		return nil
+	}
+	select {
+	case l.recvPayload <- p:
+		return nil
+	case <-c.done:
+		// The connection has ended, and the reader's next read
+		// will fail.
+		return nil
+	case <-l.closed:
+		return ErrLinkClosed
+	}
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package rawlink
+
+import "context"
+
+// LinkState is the lifecycle state of a Link. A Link moves only forward
+// through the states, from LinkRunning towards LinkClosed, but may skip
+// any of them. Requests to move to the current or an earlier state have
+// no effect.
+type LinkState int
+
+const (
+	// LinkRunning Links accept connections and send and receive data.
+	LinkRunning LinkState = iota
+	// LinkShutdown Links have asked their peers to finish sending, and
+	// continue sending queued data.
+	LinkShutdown
+	// LinkFinished Links have told their peers they will send no more
+	// data, and continue receiving.
+	LinkFinished
+	// LinkClosed Links have closed all connections.
+	LinkClosed
+)
+
+var linkStateNames = []string{"running", "shutdown", "finished", "closed"}
+
+func (s LinkState) String() string {
+	if s >= 0 && int(s) < len(linkStateNames) {
+		return linkStateNames[s]
+	}
+	return "unknown"
+}
+
+// err returns the error reported by a Link in state s.
+func (s LinkState) err() error {
+	switch s {
+	case LinkShutdown:
+		return ErrLinkShutdown
+	case LinkFinished:
+		return ErrLinkFinished
+	case LinkClosed:
+		return ErrLinkClosed
+	}
+	return nil
+}
+
+// State returns the lifecycle state of the Link.
+func (l *Link) State() LinkState {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.state
+}
+
+// Err returns nil while the Link is running, and otherwise ErrLinkShutdown,
+// ErrLinkFinished or ErrLinkClosed according to its state.
+func (l *Link) Err() error {
+	return l.State().err()
+}
+
+// Done returns a channel which is closed when the Link is closed.
+func (l *Link) Done() <-chan struct{} {
+	return l.closed
+}
+
+// Wait blocks until the Link has left the running state and all of its
+// connections and their goroutines have exited, or until ctx is done, in
+// which case it returns the context's error.
+func (l *Link) Wait(ctx context.Context) error {
+	select {
+	case <-l.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// transition moves the Link to state s, if it is later than the current
+// state, calling f with l.mutex held to apply the transition. It returns
+// the error reported in the Link's previous state.
+func (l *Link) transition(s LinkState, f func()) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	prev := l.state
+	if s <= prev {
+		return prev.err()
+	}
+	l.state = s
+	f()
+	if prev == LinkRunning {
+		// No connections are accepted after the Link leaves the
+		// running state, so the wait groups can only drain.
+		go l.stop()
+	}
+	return prev.err()
+}
+
+// spawn runs f in a goroutine counted in l.wg, so that Wait returns only
+// once it has exited. It must be called from a goroutine already counted
+// in l.wg.
+func (l *Link) spawn(f func()) {
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		f()
+	}()
+}
+
+// stop closes the Receive channel once the Link's connections have
+// stopped reading, and releases Wait once the connections and their
+// goroutines have exited.
+func (l *Link) stop() {
+	l.readWg.Wait()
+	close(l.recvPayload)
+	l.wg.Wait()
+	close(l.stopped)
+}
+
+// Close instructs the Link to close all connections. It returns the
+// error reported in the Link's previous state, nil if it was running.
+func (l *Link) Close() error {
+	return l.transition(LinkClosed, func() {
+		close(l.closed)
+	})
+}
+
+// Shutdown instructs the Link to request that the remote peers shut down
+// communication, while leaving the link in a state which can continue sending
+// any queued data.
+func (l *Link) Shutdown() error {
+	return l.transition(LinkShutdown, func() {
+		close(l.shutdown)
+	})
+}
+
+// Finish informs the peers connected to the Link that the Link will no longer
+// be sending data. The link is still able to receive data.
+func (l *Link) Finish() error {
+	return l.transition(LinkFinished, func() {
+		close(l.sendPayload)
+	})
+}
@@ -14,9 +14,11 @@ package rawlink
 
 import (
 	"errors"
+	"log/slog"
 	"sync"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	"golang.org/x/net/websocket"
 
 	"github.com/farsightsec/sielink"
@@ -28,55 +30,128 @@ type Link struct {
 	mutex            sync.Mutex
 	configMessage    *sielink.Message
 	configUpdate     chan struct{}
-	readWg           sync.WaitGroup
-	err              error
+	conns            map[*Conn]struct{}
+	subSerial        uint32
+	sendInterceptors []Interceptor
+	recvInterceptors []Interceptor
+	metrics          *linkMetrics
+	alertWatchers    alertWatchers
+	wg, readWg       sync.WaitGroup
+	state            LinkState
 	shutdown, closed chan struct{}
+	stopped          chan struct{}
+	pendingSends     int32
+	idle             chan struct{}
 
 	recvPayload, sendPayload chan *sielink.Payload
 
 	// Heartbeat specifies the interval between heartbeat messages
-	// sent on link connections. The Link attempts to send a heartbeat
+	// requested by the Link. The interval used on each connection is
+	// negotiated with the peer. Use SetHeartbeat to change the interval
+	// on established connections.
 	Heartbeat time.Duration
 
 	// TopologyFunc receives all topology messages received on the
 	// link. It is called with a nil topology when a connection closes.
-	TopologyFunc func(c *websocket.Conn, t *sielink.Topology)
+	TopologyFunc func(c *Conn, t *sielink.Topology)
 
-	// AlertFunc receives all non-fatal alerts received on the link.
-	AlertFunc func(c *websocket.Conn, a *sielink.Alert)
+	// ReceiveFunc is offered each payload received on the link before
+	// it is delivered to the Receive channel, and returns true if it
+	// has consumed the payload. It is called from the goroutine reading
+	// the connection, and blocks further reads from that connection
+	// until it returns.
+	ReceiveFunc func(c *Conn, p *sielink.Payload) bool
+
+	// AlertFunc receives the non-fatal alerts received on the link,
+	// except those suppressed by AlertInterval. It is called from the
+	// goroutine reading the connection; WatchAlerts provides a buffered
+	// alternative, which also reports the suppressed alerts.
+	AlertFunc func(c *Conn, a *sielink.Alert)
+
+	// SubscriptionFunc, if set, is called with the subscriptions
+	// received from a peer, and returns which of them are accepted
+	// and rejected. The Link sends these to the peer in a subscription
+	// acknowledgment. If SubscriptionFunc is nil, no acknowledgments
+	// are sent.
+	SubscriptionFunc func(c *Conn, subs []*sielink.Subscription) (accepted, rejected []*sielink.Subscription)
+
+	// SubscriptionAckFunc receives the subscription acknowledgments
+	// sent by peers in response to SetSubscription.
+	SubscriptionAckFunc func(c *Conn, ack *sielink.SubscriptionAck)
+
+	// AlertInterval is the minimum interval between deliveries to
+	// AlertFunc and WatchAlerts, and log records, of non-fatal alerts
+	// with the same code on the same connection. It defaults to one second; zero
+	// disables rate limiting.
+	AlertInterval time.Duration
+
+	// Logger receives structured log records of connection events. It
+	// defaults to a Logger which discards all records.
+	Logger *slog.Logger
+
+	// ClockSkewThreshold is the largest offset of a peer's clock, as
+	// measured through heartbeats, which the Link tolerates without
+	// raising a Warning alert. It defaults to one second; zero disables
+	// clock skew detection.
+	ClockSkewThreshold time.Duration
+
+	// ClockSkewFunc is called with the measured offset of the peer's
+	// clock, positive if it is ahead, when the offset first exceeds
+	// ClockSkewThreshold.
+	ClockSkewFunc func(c *Conn, offset time.Duration)
+
+	// ShutdownFunc is called when a peer requests that the Link shut
+	// down the connection, after which the Link sends no further data
+	// on it. It is called from the goroutine reading the connection.
+	ShutdownFunc func(c *Conn)
+
+	// StateFunc is called when a connection changes state. The final
+	// call for each connection reports StateClosed, with the error, if
+	// any, which HandleConnection returns.
+	StateFunc func(c *Conn, s ConnState, err error)
 }
 
 // NewLink creates a raw Link with the given configuration.
 func NewLink() *Link {
 	return &Link{
-		configMessage: newConfigMessage(nil, nil, nil),
+		configMessage: newConfigMessage(nil, nil, nil, nil),
 		configUpdate:  make(chan struct{}),
+		conns:         make(map[*Conn]struct{}),
+		metrics:       newLinkMetrics(),
 		shutdown:      make(chan struct{}),
 		closed:        make(chan struct{}),
+		stopped:       make(chan struct{}),
 		recvPayload:   make(chan *sielink.Payload, 100),
 		sendPayload:   make(chan *sielink.Payload),
-		TopologyFunc:  func(c *websocket.Conn, t *sielink.Topology) {},
-		AlertFunc:     func(c *websocket.Conn, a *sielink.Alert) {},
+		TopologyFunc:  func(c *Conn, t *sielink.Topology) {},
+		ReceiveFunc:   func(c *Conn, p *sielink.Payload) bool { return false },
+		AlertFunc:     func(c *Conn, a *sielink.Alert) {},
+		StateFunc:     func(c *Conn, s ConnState, err error) {},
+		Logger:        slog.New(discardHandler{}),
+		AlertInterval: time.Second,
+
+		SubscriptionAckFunc: func(c *Conn, ack *sielink.SubscriptionAck) {},
+		ClockSkewThreshold:  time.Second,
+		ClockSkewFunc:       func(c *Conn, offset time.Duration) {},
+		ShutdownFunc:        func(c *Conn) {},
 	}
 }
 
-var (
-	errLinkClosed   = errors.New("Link is closed")
-	errLinkShutdown = errors.New("Link is shut down")
-	errLinkFinished = errors.New("Link is finished sending")
-)
-
 // SetSubscription sets the channel subscriptions requested from peers
-// connected to the Link.
-func (l *Link) SetSubscription(subs []*sielink.Subscription) {
+// connected to the Link. It returns the serial number identifying the
+// subscription in acknowledgments received from peers.
+func (l *Link) SetSubscription(subs []*sielink.Subscription) uint32 {
 	subc := make([]*sielink.Subscription, len(subs))
 	copy(subc, subs)
 
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
-	l.configMessage = newConfigMessage(subs, l.configMessage.Topology.Path, l.configMessage.Heartbeat)
+	l.subSerial++
+	l.configMessage = newConfigMessage(subc, proto.Uint32(l.subSerial),
+		l.configMessage.Topology.Path, l.configMessage.Heartbeat)
 	close(l.configUpdate)
 	l.configUpdate = make(chan struct{})
+	return l.subSerial
 }
 
 // SetPath sets the paths advertised to peers connected to the
@@ -88,7 +163,8 @@ func (l *Link) SetPath(paths []*sielink.Path) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
-	l.configMessage = newConfigMessage(l.configMessage.Topology.Subscription, paths, l.configMessage.Heartbeat)
+	l.configMessage = newConfigMessage(l.configMessage.Topology.Subscription,
+		l.configMessage.Topology.SubscriptionSerial, paths, l.configMessage.Heartbeat)
 	close(l.configUpdate)
 	l.configUpdate = make(chan struct{})
 }
@@ -98,73 +174,85 @@ func (l *Link) Receive() <-chan *sielink.Payload {
 	return l.recvPayload
 }
 
-// Send sends a payload on an available connection.
+// ReceiveQueueDepth returns the number of received payloads awaiting
+// delivery through Receive. The receive queue is shared by all
+// connections on the Link.
+func (l *Link) ReceiveQueueDepth() int {
+	return len(l.recvPayload)
+}
+
+// Send sends a payload on an available connection. It returns
+// ErrLinkFinished if Finish has been called, or ErrLinkClosed if the
+// Link is closed before the payload can be sent.
 func (l *Link) Send(p *sielink.Payload) (err error) {
+	l.beginSend()
+	defer l.endSend()
 	defer func() {
 		if r := recover(); r != nil {
-			if err != nil {
-				err = l.err
-				return
-			}
-			err = errLinkClosed
+			err = l.Err()
 		}
 	}()
-	l.sendPayload <- p
-	return
-}
-
-func (l *Link) closeReader() {
-	defer func() { recover() }()
-	l.readWg.Wait()
-	close(l.recvPayload)
-}
-
-// Close instructs the Link to close all connections.
-func (l *Link) Close() (err error) {
-	l.mutex.Lock()
-	defer l.mutex.Unlock()
-	err = l.err
-	l.err = errLinkClosed
-	close(l.closed)
-	go l.closeReader()
-	return
-}
-
-// Shutdown instructs the Link to request that the remote peers shut down
-// communication, while leaving the link in a state which can continue sending
-// any queued data.
-func (l *Link) Shutdown() (err error) {
-	l.mutex.Lock()
-	defer l.mutex.Unlock()
-	err = l.err
-	l.err = errLinkShutdown
-	close(l.shutdown)
-	go l.closeReader()
-	return
-}
-
-// Finish informs the peers connected to the Link that the Link will no longer
-// be sending data. The link is still able to receive data.
-func (l *Link) Finish() (err error) {
-	l.mutex.Lock()
-	defer l.mutex.Unlock()
-	err = l.err
-	l.err = errLinkFinished
-	close(l.sendPayload)
-	go l.closeReader()
-	return
+	select {
+	case l.sendPayload <- p:
+		return nil
+	case <-l.closed:
+		return ErrLinkClosed
+	}
 }
 
 // HandleConnection passes control over a websocket connection to the Link,
 // returning when the connection closes.
-func (l *Link) HandleConnection(c *websocket.Conn) error {
+func (l *Link) HandleConnection(ws *websocket.Conn) error {
+	c := newConn(ws)
 	l.mutex.Lock()
-	if l.err != nil {
-		writeAlert(c, l.err)
-		c.Close()
+	if err := l.state.err(); err != nil {
+		l.writeAlert(c, err)
+		ws.Close()
 		l.mutex.Unlock()
-		return l.err
+		l.log(c).Warn("rejected connection", "err", err)
+		l.setState(c, StateClosed, err)
+		return err
 	}
+	l.conns[c] = struct{}{}
+	l.wg.Add(1)
+	l.readWg.Add(1)
 	l.mutex.Unlock()
-	return l.runConnection(c)
+	defer l.wg.Done()
+	l.metrics.addPeer(c.metricsPeer)
+	err := l.runConnection(c)
+	l.metrics.removePeer(c.metricsPeer)
+	l.mutex.Lock()
+	delete(l.conns, c)
+	l.mutex.Unlock()
+	if err != nil {
+		l.log(c).Warn("connection closed", "err", err)
+	} else {
+		l.log(c).Info("connection closed")
+	}
+	l.setState(c, StateClosed, err)
+	return err
+}
+
+// SendAlert sends an alert with the given level, code and message to the
+// peer on c, or to all peers connected to the Link if c is nil. Alerts
+// below FatalError inform the peer without ending the connection; peers
+// close connections on which they receive a FatalError alert. Codes are
+// listed in the sielink package.
+func (l *Link) SendAlert(c *Conn, level sielink.AlertLevel, code uint32, message string) error {
+	alert := &sielink.Alert{
+		Level:   level.Enum(),
+		Code:    proto.Uint32(code),
+		Message: proto.String(message),
+	}
+	if c != nil {
+		return l.sendAlert(c, alert)
+	}
+
+	var errs []error
+	for _, c := range l.connList() {
+		if err := l.sendAlert(c, alert); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
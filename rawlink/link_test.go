@@ -9,9 +9,18 @@
 package rawlink_test
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -44,13 +53,18 @@ type testLink struct {
 	serverURL              string
 }
 
-func newTestLink(t *testing.T, name string, nconn int) *testLink {
+// newTestLink connects nconn connections between a new client and server
+// Link, after calling each setup function to configure the Links.
+func newTestLink(t *testing.T, name string, nconn int, setup ...func(tl *testLink)) *testLink {
 	path := "/" + name
 	tl := &testLink{
 		clientLink: rawlink.NewLink(),
 		serverLink: rawlink.NewLink(),
 		serverURL:  serverURL + path,
 	}
+	for _, f := range setup {
+		f(tl)
+	}
 
 	tl.clientWg.Add(nconn)
 	tl.serverWg.Add(nconn)
@@ -63,11 +77,17 @@ func newTestLink(t *testing.T, name string, nconn int) *testLink {
 
 	for i := 0; i < nconn; i++ {
 		go func() {
-			if err := testDial(tl.clientLink, tl.serverURL); err != nil {
+			// Only connections which fail to reach the server
+			// are counted here; the server handler counts the
+			// others, however they end.
+			conn, err := websocket.Dial(tl.serverURL, "", clientURL)
+			if err != nil {
 				t.Log(err)
 				tl.clientWg.Done()
 				tl.serverWg.Done()
+				return
 			}
+			tl.clientLink.HandleConnection(conn)
 		}()
 	}
 
@@ -93,6 +113,25 @@ func waitFor(t time.Duration, f func()) error {
 	return nil
 }
 
+// syncBuffer is a bytes.Buffer safe for concurrent use by a Logger and
+// the test.
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
 func init() {
 	go func() {
 		log.Fatal(http.ListenAndServe(server, nil))
@@ -146,6 +185,257 @@ func TestLinkShutdown(t *testing.T) {
 	tl.clientLink.Close()
 }
 
+// Repeat and reorder lifecycle transitions, verify they do not panic,
+// report the prior state, and that Wait returns once all connections
+// have exited.
+func TestLinkLifecycle(t *testing.T) {
+	tl := newTestLink(t, "TestLinkLifecycle", 3)
+	cl, sl := tl.clientLink, tl.serverLink
+
+	if cl.State() != rawlink.LinkRunning || cl.Err() != nil {
+		t.Errorf("new Link in state %v, error %v", cl.State(), cl.Err())
+	}
+	steps := []struct {
+		f      func() error
+		prev   error
+		state  rawlink.LinkState
+		errNow error
+	}{
+		{cl.Shutdown, nil, rawlink.LinkShutdown, rawlink.ErrLinkShutdown},
+		{cl.Shutdown, rawlink.ErrLinkShutdown, rawlink.LinkShutdown, rawlink.ErrLinkShutdown},
+		{cl.Finish, rawlink.ErrLinkShutdown, rawlink.LinkFinished, rawlink.ErrLinkFinished},
+		{cl.Shutdown, rawlink.ErrLinkFinished, rawlink.LinkFinished, rawlink.ErrLinkFinished},
+		{cl.Finish, rawlink.ErrLinkFinished, rawlink.LinkFinished, rawlink.ErrLinkFinished},
+		{cl.Close, rawlink.ErrLinkFinished, rawlink.LinkClosed, rawlink.ErrLinkClosed},
+		{cl.Close, rawlink.ErrLinkClosed, rawlink.LinkClosed, rawlink.ErrLinkClosed},
+		{cl.Finish, rawlink.ErrLinkClosed, rawlink.LinkClosed, rawlink.ErrLinkClosed},
+	}
+	for i, s := range steps {
+		if err := s.f(); err != s.prev {
+			t.Errorf("step %d returned %v, expected %v", i, err, s.prev)
+		}
+		if cl.State() != s.state || cl.Err() != s.errNow {
+			t.Errorf("step %d: state %v, error %v; expected %v, %v",
+				i, cl.State(), cl.Err(), s.state, s.errNow)
+		}
+	}
+	select {
+	case <-cl.Done():
+	default:
+		t.Error("Done not closed after Close")
+	}
+	if err := cl.Send(&sielink.Payload{Channel: proto.Uint32(1)}); err != rawlink.ErrLinkClosed {
+		t.Errorf("Send returned %v, expected %v", err, rawlink.ErrLinkClosed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := cl.Wait(ctx); err != nil {
+		t.Errorf("client Wait returned %v", err)
+	}
+	if len(cl.Connections()) != 0 {
+		t.Error("client connections remain after Wait")
+	}
+	if _, ok := <-cl.Receive(); ok {
+		t.Error("Receive channel open after Wait")
+	}
+
+	// The server Link stays running, so Wait must not return.
+	short, cancel2 := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel2()
+	if err := sl.Wait(short); err != context.DeadlineExceeded {
+		t.Errorf("running server Wait returned %v", err)
+	}
+	select {
+	case <-sl.Done():
+		t.Error("Done closed on running Link")
+	default:
+	}
+	sl.Close()
+	if err := sl.Wait(ctx); err != nil {
+		t.Errorf("server Wait returned %v", err)
+	}
+}
+
+// Drain a Link while payloads are waiting to be sent, and verify that every
+// payload reaches the peer before the connections end.
+func TestLinkDrain(t *testing.T) {
+	tl := newTestLink(t, "TestLinkDrain", 2)
+	var received int32
+	go func() {
+		for range tl.serverLink.Receive() {
+			atomic.AddInt32(&received, 1)
+		}
+	}()
+
+	// Hold the first payload on each connection until Drain starts, so
+	// the remaining Send calls are pending.
+	var writing int32
+	release := make(chan struct{})
+	tl.clientLink.AddSendInterceptor(func(c *rawlink.Conn, p *sielink.Payload) (*sielink.Payload, error) {
+		atomic.AddInt32(&writing, 1)
+		<-release
+		return p, nil
+	})
+
+	const n = 20
+	var sent int32
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tl.clientLink.Send(&sielink.Payload{Channel: proto.Uint32(1)}) == nil {
+				atomic.AddInt32(&sent, 1)
+			}
+		}()
+	}
+	for atomic.LoadInt32(&writing) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+	unsent, err := tl.clientLink.Drain(ctx)
+	if unsent != 0 || err != nil {
+		t.Errorf("Drain returned %d, %v", unsent, err)
+	}
+	wg.Wait()
+	if err := waitFor(time.Second, tl.serverWg.Wait); err != nil {
+		t.Error("server connections did not end")
+	}
+	waitFor(time.Second, func() {
+		for atomic.LoadInt32(&received) < n {
+			time.Sleep(time.Millisecond)
+		}
+	})
+	if s, r := atomic.LoadInt32(&sent), atomic.LoadInt32(&received); s != n || r != n {
+		t.Errorf("sent %d payloads, peer received %d, expected %d", s, r, n)
+	}
+	tl.serverLink.Close()
+}
+
+// Drain a Link with no connections, and verify that it closes the Link
+// at the deadline, reporting the payloads left unsent.
+func TestLinkDrainDeadline(t *testing.T) {
+	l := rawlink.NewLink()
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			errs <- l.Send(&sielink.Payload{Channel: proto.Uint32(1)})
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	unsent, err := l.Drain(ctx)
+	if unsent != 3 || err != context.DeadlineExceeded {
+		t.Errorf("Drain returned %d, %v; expected 3, %v", unsent, err, context.DeadlineExceeded)
+	}
+	for i := 0; i < 3; i++ {
+		if err := <-errs; err != rawlink.ErrLinkClosed {
+			t.Errorf("Send returned %v, expected %v", err, rawlink.ErrLinkClosed)
+		}
+	}
+	if l.State() != rawlink.LinkClosed {
+		t.Errorf("Link in state %v after Drain deadline", l.State())
+	}
+}
+
+// linkGoroutines returns the number of goroutines running methods of
+// any of links, identified by the receiver in their stack traces.
+func linkGoroutines(links ...*rawlink.Link) int {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	var receivers []string
+	for _, l := range links {
+		receivers = append(receivers, regexp.QuoteMeta(fmt.Sprintf("%p", l)))
+	}
+	re := regexp.MustCompile(`sielink/rawlink\.\(\*Link\)\.[^(\n]*\((` +
+		strings.Join(receivers, "|") + `)[,)]`)
+	count := 0
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		if re.MatchString(g) {
+			count++
+		}
+	}
+	return count
+}
+
+// Terminate connections with each lifecycle transition and by closing
+// the peer's connection, and verify that no connection goroutines remain.
+func TestLinkLeaks(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		end  func(tl *testLink)
+	}{
+		{"Close", func(tl *testLink) {
+			tl.clientLink.Close()
+		}},
+		{"Shutdown", func(tl *testLink) {
+			tl.clientLink.Shutdown()
+			// The server finishes in response, ending the
+			// client's receive side.
+			for range tl.clientLink.Receive() {
+			}
+			tl.clientLink.Finish()
+		}},
+		{"Finish", func(tl *testLink) {
+			tl.clientLink.Finish()
+			tl.serverLink.Finish()
+		}},
+		{"Remote", func(tl *testLink) {
+			for _, c := range tl.serverLink.Connections() {
+				c.Conn.WebSocket().Close()
+			}
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tl := newTestLink(t, "TestLinkLeaks"+tc.name, 3)
+			tl.clientLink.SetHeartbeat(10 * time.Millisecond)
+			if linkGoroutines(tl.clientLink, tl.serverLink) == 0 {
+				t.Fatal("connection goroutines not found")
+			}
+			tc.end(tl)
+			if err := waitFor(time.Second, tl.serverWg.Wait); err != nil {
+				t.Fatal("server connections did not end")
+			}
+			// Neither Link has goroutines of its own once its
+			// connections have ended, whether or not it is closed.
+			err := waitFor(time.Second, func() {
+				for linkGoroutines(tl.clientLink, tl.serverLink) > 0 {
+					time.Sleep(10 * time.Millisecond)
+				}
+			})
+			if err != nil {
+				t.Errorf("%d goroutines remain",
+					linkGoroutines(tl.clientLink, tl.serverLink))
+			}
+			tl.clientLink.Close()
+			tl.serverLink.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			for _, l := range []*rawlink.Link{tl.clientLink, tl.serverLink} {
+				if err := l.Wait(ctx); err != nil {
+					t.Errorf("Wait returned %v", err)
+				}
+			}
+		})
+	}
+}
+
 // Use SetSubscription and SetPath to trigger sending of control messages.
 // Verify that control messages are sent to ControlFunc
 // Verify that connection exit is reported to ControlFunc (m == nil)
@@ -158,7 +448,7 @@ func TestLinkControlMessage(t *testing.T) {
 	cwg.Add(nconn)
 	scwg.Add(nconn)
 	ccwg.Add(nconn)
-	tl.serverLink.TopologyFunc = func(c *websocket.Conn, m *sielink.Topology) {
+	tl.serverLink.TopologyFunc = func(c *rawlink.Conn, m *sielink.Topology) {
 		t.Log("ServerLink: ", m)
 		if m == nil {
 			swg.Done()
@@ -167,7 +457,7 @@ func TestLinkControlMessage(t *testing.T) {
 		scwg.Done()
 	}
 
-	tl.clientLink.TopologyFunc = func(c *websocket.Conn, m *sielink.Topology) {
+	tl.clientLink.TopologyFunc = func(c *rawlink.Conn, m *sielink.Topology) {
 		t.Log("ClientLink: ", m)
 		if m == nil {
 			cwg.Done()
@@ -202,6 +492,578 @@ func TestLinkControlMessage(t *testing.T) {
 	}
 }
 
+// Subscribe to a channel range, and verify the subscription is matched
+// by the remote Link.
+func TestLinkSubscribed(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	tl := newTestLink(t, "TestLinkSubscribed", 1, func(tl *testLink) {
+		tl.serverLink.TopologyFunc = func(c *rawlink.Conn, m *sielink.Topology) {
+			if len(m.GetSubscription()) > 0 {
+				wg.Done()
+			}
+		}
+	})
+	tl.clientLink.SetSubscription([]*sielink.Subscription{
+		&sielink.Subscription{
+			Channel: []uint32{5},
+			Range: []*sielink.ChannelRange{
+				{First: proto.Uint32(200), Last: proto.Uint32(299)},
+			},
+		},
+	})
+	if err := waitFor(time.Second, wg.Wait); err != nil {
+		t.Fatal(err)
+	}
+	for ch, expected := range map[uint32]bool{5: true, 6: false, 200: true, 250: true, 300: false} {
+		p := &sielink.Payload{Channel: proto.Uint32(ch)}
+		if tl.serverLink.Subscribed(p) != expected {
+			t.Errorf("Subscribed(channel %d) != %v", ch, expected)
+		}
+	}
+	tl.clientLink.Close()
+	tl.serverLink.Close()
+}
+
+// Install send and receive interceptors which modify and drop payloads,
+// and verify the payloads received.
+func TestLinkInterceptors(t *testing.T) {
+	tl := newTestLink(t, "TestLinkInterceptors", 1)
+	var sent int32
+	tl.clientLink.AddSendInterceptor(func(c *rawlink.Conn, p *sielink.Payload) (*sielink.Payload, error) {
+		atomic.AddInt32(&sent, 1)
+		return p, nil
+	})
+	tl.serverLink.AddReceiveInterceptor(func(c *rawlink.Conn, p *sielink.Payload) (*sielink.Payload, error) {
+		if p.GetChannel() == 1 {
+			return nil, nil
+		}
+		return p, nil
+	})
+	tl.serverLink.AddReceiveInterceptor(func(c *rawlink.Conn, p *sielink.Payload) (*sielink.Payload, error) {
+		p.Data = []byte("redacted")
+		return p, nil
+	})
+
+	for _, ch := range []uint32{1, 2} {
+		if err := tl.clientLink.Send(&sielink.Payload{
+			Channel: proto.Uint32(ch),
+			Data:    []byte("secret"),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case p := <-tl.serverLink.Receive():
+		if p.GetChannel() != 2 || string(p.Data) != "redacted" {
+			t.Errorf("received unexpected payload %v", p)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for payload")
+	}
+	if n := atomic.LoadInt32(&sent); n != 2 {
+		t.Errorf("send interceptor called %d times, expected 2", n)
+	}
+	tl.clientLink.Close()
+	tl.serverLink.Close()
+}
+
+// Verify the connection metadata presented to Link callbacks.
+func TestLinkConn(t *testing.T) {
+	conns := make(chan *rawlink.Conn, 1)
+	subscribed := make(chan struct{})
+	tl := newTestLink(t, "TestLinkConn", 1, func(tl *testLink) {
+		tl.serverLink.ReceiveFunc = func(c *rawlink.Conn, p *sielink.Payload) bool {
+			conns <- c
+			return true
+		}
+		tl.serverLink.TopologyFunc = func(c *rawlink.Conn, m *sielink.Topology) {
+			if len(m.GetSubscription()) > 0 {
+				close(subscribed)
+			}
+		}
+	})
+	tl.clientLink.SetSubscription([]*sielink.Subscription{
+		&sielink.Subscription{Channel: []uint32{5}},
+	})
+	if err := waitFor(time.Second, func() { <-subscribed }); err != nil {
+		t.Fatal(err)
+	}
+	tl.clientLink.Send(&sielink.Payload{Channel: proto.Uint32(5), Data: []byte("data")})
+
+	var c *rawlink.Conn
+	select {
+	case c = <-conns:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for payload")
+	}
+	if c.Version() != sielink.ProtocolVersion {
+		t.Errorf("negotiated version %d, expected %d", c.Version(), sielink.ProtocolVersion)
+	}
+	if c.RemoteAddr() == "" || c.Connected().IsZero() {
+		t.Errorf("missing remote address or connect time")
+	}
+	if st := c.Stats(); st.PayloadsReceived != 1 || st.BytesReceived != 4 {
+		t.Errorf("incorrect counters %+v", st)
+	}
+	if len(c.Topology().GetSubscription()) != 1 {
+		t.Errorf("incorrect remote topology %v", c.Topology())
+	}
+
+	var b strings.Builder
+	tl.serverLink.Metrics().WriteTo(&b)
+	for _, m := range []string{
+		`sielink_payloads_received_total{channel="5"} 1`,
+		`sielink_payload_bytes_received_total{channel="5"} 4`,
+		`sielink_connections{state="established"} 1`,
+	} {
+		if !strings.Contains(b.String(), m+"\n") {
+			t.Errorf("metrics missing %s", m)
+		}
+	}
+	tl.clientLink.Close()
+	tl.serverLink.Close()
+}
+
+// Verify the connection snapshots returned by Link.Connections.
+func TestLinkConnections(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(3)
+	tl := newTestLink(t, "TestLinkConnections", 3, func(tl *testLink) {
+		tl.clientLink.TopologyFunc = func(c *rawlink.Conn, m *sielink.Topology) {
+			if len(m.GetPath()) > 0 {
+				wg.Done()
+			}
+		}
+	})
+	tl.serverLink.SetPath([]*sielink.Path{
+		&sielink.Path{Metric: proto.Uint64(1000), Site: []uint32{5}},
+	})
+	if err := waitFor(time.Second, wg.Wait); err != nil {
+		t.Fatal(err)
+	}
+
+	conns := tl.clientLink.Connections()
+	if len(conns) != 3 {
+		t.Fatalf("%d connections, expected 3", len(conns))
+	}
+	for _, ci := range conns {
+		if ci.State != rawlink.StateEstablished || ci.PeerState != rawlink.PeerNormal {
+			t.Errorf("connection in state %s, %s", ci.State, ci.PeerState)
+		}
+		if ci.Version != sielink.ProtocolVersion || len(ci.Path) != 1 {
+			t.Errorf("incorrect connection snapshot %+v", ci)
+		}
+	}
+	tl.clientLink.Close()
+	tl.serverLink.Close()
+}
+
+// Verify that payloads awaiting delivery are counted by ReceiveQueueDepth.
+func TestLinkReceiveQueueDepth(t *testing.T) {
+	subscribed := make(chan struct{})
+	tl := newTestLink(t, "TestLinkReceiveQueueDepth", 1, func(tl *testLink) {
+		tl.clientLink.TopologyFunc = func(c *rawlink.Conn, m *sielink.Topology) {
+			if len(m.GetSubscription()) > 0 {
+				close(subscribed)
+			}
+		}
+	})
+	tl.serverLink.SetSubscription([]*sielink.Subscription{
+		&sielink.Subscription{Channel: []uint32{5}},
+	})
+	if err := waitFor(time.Second, func() { <-subscribed }); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		tl.clientLink.Send(&sielink.Payload{Channel: proto.Uint32(5)})
+	}
+	deadline := time.Now().Add(time.Second)
+	for tl.serverLink.ReceiveQueueDepth() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("receive queue depth %d, expected 2",
+				tl.serverLink.ReceiveQueueDepth())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	<-tl.serverLink.Receive()
+	if n := tl.serverLink.ReceiveQueueDepth(); n != 1 {
+		t.Errorf("receive queue depth %d after Receive, expected 1", n)
+	}
+	tl.clientLink.Close()
+	tl.serverLink.Close()
+}
+
+// Send a non-fatal alert to all peers, and verify it is received without
+// ending the connections.
+func TestLinkSendAlert(t *testing.T) {
+	tl := newTestLink(t, "TestLinkSendAlert", 2)
+	alerts := make(chan *sielink.Alert, 2)
+	tl.clientLink.AlertFunc = func(c *rawlink.Conn, a *sielink.Alert) {
+		alerts <- a
+	}
+	err := tl.serverLink.SendAlert(nil, sielink.AlertLevel_Warning,
+		sielink.AlertCodeQuotaWarning, "quota nearly exhausted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case a := <-alerts:
+			if a.GetLevel() != sielink.AlertLevel_Warning ||
+				a.GetCode() != sielink.AlertCodeQuotaWarning {
+				t.Errorf("received unexpected alert %v", a)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for alert")
+		}
+	}
+	if n := len(tl.clientLink.Connections()); n != 2 {
+		t.Errorf("%d connections after alert, expected 2", n)
+	}
+	tl.clientLink.Close()
+	tl.serverLink.Close()
+}
+
+// Send repeated alerts, and verify they are rate limited and counted in
+// the alert event stream.
+func TestLinkWatchAlerts(t *testing.T) {
+	var funcAlerts int32
+	tl := newTestLink(t, "TestLinkWatchAlerts", 1, func(tl *testLink) {
+		tl.clientLink.AlertFunc = func(c *rawlink.Conn, a *sielink.Alert) {
+			atomic.AddInt32(&funcAlerts, 1)
+		}
+	})
+	events, stop := tl.clientLink.WatchAlerts()
+	defer stop()
+	sendAlerts := func(code uint32, n int) {
+		for i := 0; i < n; i++ {
+			err := tl.serverLink.SendAlert(nil, sielink.AlertLevel_Warning, code, "warning")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	expect := func(code uint32, suppressed int) {
+		select {
+		case ev := <-events:
+			if ev.Alert.GetCode() != code || ev.Suppressed != suppressed {
+				t.Errorf("received code %d with %d suppressed, expected code %d with %d",
+					ev.Alert.GetCode(), ev.Suppressed, code, suppressed)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for alert code %d", code)
+		}
+	}
+
+	sendAlerts(sielink.AlertCodeQuotaWarning, 5)
+	sendAlerts(sielink.AlertCodeDeprecated, 1)
+	expect(sielink.AlertCodeQuotaWarning, 0)
+	expect(sielink.AlertCodeDeprecated, 0)
+	select {
+	case ev := <-events:
+		t.Errorf("repeated alert not suppressed: %v", ev.Alert)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The last suppressed alert is delivered at the end of the
+	// interval, without waiting for another alert.
+	expect(sielink.AlertCodeQuotaWarning, 3)
+
+	// Alerts still suppressed are delivered when the connection closes.
+	sendAlerts(sielink.AlertCodeQuotaWarning, 3)
+	sendAlerts(sielink.AlertCodeBadChannel, 1)
+	expect(sielink.AlertCodeBadChannel, 0)
+	tl.serverLink.Close()
+	expect(sielink.AlertCodeQuotaWarning, 2)
+	tl.clientLink.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := tl.clientLink.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&funcAlerts); n != 3 {
+		t.Errorf("AlertFunc called %d times, expected 3", n)
+	}
+}
+
+// Connect Links requesting different heartbeat intervals, and verify the
+// negotiated interval, including after a change on a live connection.
+func TestLinkHeartbeat(t *testing.T) {
+	sl := rawlink.NewLink()
+	sl.Heartbeat = 200 * time.Millisecond
+	http.Handle("/TestLinkHeartbeat", websocket.Handler(
+		func(c *websocket.Conn) {
+			sl.HandleConnection(c)
+		}))
+	cl := rawlink.NewLink()
+	cl.Heartbeat = 50 * time.Millisecond
+	go testDial(cl, serverURL+"/TestLinkHeartbeat")
+
+	negotiated := func(l *rawlink.Link, d time.Duration) func() {
+		return func() {
+			for {
+				conns := l.Connections()
+				if len(conns) == 1 && conns[0].NegotiatedHeartbeat == d {
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+	}
+	for _, l := range []*rawlink.Link{cl, sl} {
+		if err := waitFor(time.Second, negotiated(l, 50*time.Millisecond)); err != nil {
+			t.Fatal("initial heartbeat not negotiated")
+		}
+	}
+	cl.SetHeartbeat(time.Second)
+	for _, l := range []*rawlink.Link{cl, sl} {
+		if err := waitFor(time.Second, negotiated(l, 200*time.Millisecond)); err != nil {
+			t.Fatal("changed heartbeat not negotiated")
+		}
+	}
+	cl.Close()
+	sl.Close()
+}
+
+// Exchange timestamped heartbeats, and verify both Links measure the
+// round-trip time and a plausible clock offset.
+func TestLinkRTT(t *testing.T) {
+	sl := rawlink.NewLink()
+	sl.Heartbeat = 20 * time.Millisecond
+	http.Handle("/TestLinkRTT", websocket.Handler(
+		func(c *websocket.Conn) {
+			sl.HandleConnection(c)
+		}))
+	cl := rawlink.NewLink()
+	cl.Heartbeat = 20 * time.Millisecond
+	go testDial(cl, serverURL+"/TestLinkRTT")
+
+	for _, l := range []*rawlink.Link{cl, sl} {
+		err := waitFor(time.Second, func() {
+			for {
+				conns := l.Connections()
+				if len(conns) == 1 && conns[0].RTT > 0 {
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+		if err != nil {
+			t.Fatal("round-trip time not measured")
+		}
+		c := l.Connections()[0]
+		if c.RTT > time.Second {
+			t.Errorf("implausible round-trip time %v", c.RTT)
+		}
+		if off := c.Conn.ClockOffset(); off > 100*time.Millisecond || off < -100*time.Millisecond {
+			t.Errorf("implausible clock offset %v", off)
+		}
+		var b bytes.Buffer
+		l.Metrics().WriteTo(&b)
+		if !strings.Contains(b.String(), `sielink_rtt_seconds_count{peer="`) {
+			t.Errorf("round-trip time missing from metrics:\n%s", b.String())
+		}
+	}
+	cl.Close()
+	sl.Close()
+
+	// The peer's timing series are removed once its connection closes.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, l := range []*rawlink.Link{cl, sl} {
+		if err := l.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		l.Metrics().WriteTo(&b)
+		if strings.Contains(b.String(), `{peer="`) {
+			t.Errorf("timing series remain after close:\n%s", b.String())
+		}
+	}
+}
+
+// Set the principal of a connection after it has started, and verify
+// that round-trip times are still recorded in the peer's metrics.
+func TestLinkRTTPrincipal(t *testing.T) {
+	sl := rawlink.NewLink()
+	sl.Heartbeat = 20 * time.Millisecond
+	sl.TopologyFunc = func(c *rawlink.Conn, m *sielink.Topology) {
+		if m != nil {
+			c.SetPrincipal("sensor")
+		}
+	}
+	http.Handle("/TestLinkRTTPrincipal", websocket.Handler(
+		func(c *websocket.Conn) {
+			sl.HandleConnection(c)
+		}))
+	cl := rawlink.NewLink()
+	cl.Heartbeat = 20 * time.Millisecond
+	go testDial(cl, serverURL+"/TestLinkRTTPrincipal")
+
+	countRe := regexp.MustCompile(`sielink_rtt_seconds_count\{peer="[^"]*"\} (\d+)`)
+	rttCount := func() int {
+		var b bytes.Buffer
+		sl.Metrics().WriteTo(&b)
+		m := countRe.FindStringSubmatch(b.String())
+		if m == nil {
+			return 0
+		}
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	err := waitFor(time.Second, func() {
+		for {
+			conns := sl.Connections()
+			if len(conns) == 1 && conns[0].Conn.Principal() == "sensor" {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+	if err != nil {
+		t.Fatal("principal not set")
+	}
+	before := rttCount()
+	err = waitFor(time.Second, func() {
+		for rttCount() < before+3 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+	if err != nil {
+		t.Errorf("round-trip times not recorded after principal set: %d before, %d after",
+			before, rttCount())
+	}
+	cl.Close()
+	sl.Close()
+}
+
+// Exchange timestamped heartbeats with a peer whose clock is ten seconds
+// ahead, and verify the Link reports the skew locally and to the peer.
+func TestLinkClockSkew(t *testing.T) {
+	const skew = 10 * time.Second
+	alerts := make(chan *sielink.Alert, 10)
+	http.Handle("/TestLinkClockSkew", websocket.Handler(
+		func(c *websocket.Conn) {
+			var mutex sync.Mutex
+			var echo uint64
+			var echoAt time.Time
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				var b []byte
+				for websocket.Message.Receive(c, &b) == nil {
+					m := new(sielink.Message)
+					if proto.Unmarshal(b, m) != nil {
+						return
+					}
+					mutex.Lock()
+					if m.Timestamp != nil {
+						echo, echoAt = m.GetTimestamp(), time.Now()
+					}
+					mutex.Unlock()
+					if m.Alert != nil {
+						alerts <- m.Alert
+					}
+				}
+			}()
+
+			m := &sielink.Message{
+				ProtocolVersion: sielink.SupportedVersions,
+				MessageType:     sielink.MessageType_TopologyMessage.Enum(),
+				Heartbeat:       proto.Uint32(20),
+				Topology:        &sielink.Topology{},
+			}
+			for {
+				b, _ := proto.Marshal(m)
+				if websocket.Message.Send(c, b) != nil {
+					return
+				}
+				select {
+				case <-done:
+					return
+				case <-time.After(20 * time.Millisecond):
+				}
+				now := time.Now()
+				m = &sielink.Message{
+					ProtocolVersion: sielink.SupportedVersions,
+					MessageType:     sielink.MessageType_Heartbeat.Enum(),
+					Heartbeat:       proto.Uint32(20),
+					Timestamp:       proto.Uint64(uint64(now.Add(skew).UnixMicro())),
+				}
+				mutex.Lock()
+				if echo != 0 {
+					m.EchoTimestamp = proto.Uint64(echo)
+					m.EchoDelay = proto.Uint64(uint64(now.Sub(echoAt) / time.Microsecond))
+				}
+				mutex.Unlock()
+			}
+		}))
+
+	offsets := make(chan time.Duration, 1)
+	cl := rawlink.NewLink()
+	cl.Heartbeat = 20 * time.Millisecond
+	cl.ClockSkewFunc = func(c *rawlink.Conn, offset time.Duration) {
+		offsets <- offset
+	}
+	go testDial(cl, serverURL+"/TestLinkClockSkew")
+
+	select {
+	case offset := <-offsets:
+		if offset < skew-100*time.Millisecond || offset > skew+100*time.Millisecond {
+			t.Errorf("measured offset %v, expected %v", offset, skew)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("clock skew not detected")
+	}
+	select {
+	case a := <-alerts:
+		if a.GetLevel() != sielink.AlertLevel_Warning || a.GetCode() != sielink.AlertCodeClockSkew {
+			t.Errorf("received alert %v, expected clock skew warning", a)
+		}
+	case <-time.After(time.Second):
+		t.Error("no clock skew alert sent")
+	}
+	if conns := cl.Connections(); len(conns) != 1 || !conns[0].ClockSkewed {
+		t.Error("connection not marked as clock skewed")
+	}
+	cl.Close()
+}
+
+// Respond with a configuration announcing heartbeats, but send none,
+// and verify the client connection returns a heartbeat timeout.
+func TestLinkHeartbeatTimeout(t *testing.T) {
+	http.Handle("/TestLinkHeartbeatTimeout", websocket.Handler(
+		func(c *websocket.Conn) {
+			m := &sielink.Message{
+				ProtocolVersion: sielink.SupportedVersions,
+				MessageType:     sielink.MessageType_TopologyMessage.Enum(),
+				Heartbeat:       proto.Uint32(50),
+				Topology:        &sielink.Topology{},
+			}
+			b, err := proto.Marshal(m)
+			if err != nil {
+				return
+			}
+			websocket.Message.Send(c, b)
+			for websocket.Message.Receive(c, &b) == nil {
+			}
+		}))
+
+	cl := rawlink.NewLink()
+	err := waitFor(time.Second, func() {
+		derr := testDial(cl, serverURL+"/TestLinkHeartbeatTimeout")
+		var herr *rawlink.HeartbeatTimeoutError
+		if !errors.As(derr, &herr) || herr.Interval != 50*time.Millisecond {
+			t.Errorf("HandleConnection returned %v, expected heartbeat timeout", derr)
+		}
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 // Respond with alert message, verify client connection returns error.
 func TestLinkAlert(t *testing.T) {
 	alert := &sielink.Alert{
@@ -225,7 +1087,12 @@ func TestLinkAlert(t *testing.T) {
 	cl := rawlink.NewLink()
 
 	err := waitFor(time.Second, func() {
-		t.Log(testDial(cl, serverURL+"/TestLinkAlert"))
+		derr := testDial(cl, serverURL+"/TestLinkAlert")
+		t.Log(derr)
+		var ra *rawlink.RemoteAlertError
+		if !errors.As(derr, &ra) || ra.Alert.GetMessage() != "Test Alert" {
+			t.Errorf("HandleConnection returned %v, expected remote alert", derr)
+		}
 	})
 	if err != nil {
 		t.Error(err)
@@ -249,12 +1116,51 @@ func TestLinkVersion(t *testing.T) {
 		}))
 
 	cl := rawlink.NewLink()
+	var log syncBuffer
+	cl.Logger = slog.New(slog.NewTextHandler(&log, nil))
 
 	err := waitFor(time.Second, func() {
-		t.Log(testDial(cl, serverURL+"/TestLinkVersion"))
+		derr := testDial(cl, serverURL+"/TestLinkVersion")
+		t.Log(derr)
+		if !errors.Is(derr, rawlink.ErrVersionMismatch) {
+			t.Errorf("HandleConnection returned %v, expected version mismatch", derr)
+		}
 	})
 	if err != nil {
 		t.Error(err)
 	}
+	for _, msg := range []string{"protocol version mismatch", "sent alert", "connection closed"} {
+		if !strings.Contains(log.String(), msg) {
+			t.Errorf("log missing %q: %s", msg, log.String())
+		}
+	}
+
+}
+
+// Verify the errors returned by Send after Finish and Close, and by
+// connections to a closed Link.
+func TestLinkErrors(t *testing.T) {
+	fl := rawlink.NewLink()
+	fl.Finish()
+	if err := fl.Send(&sielink.Payload{}); !errors.Is(err, rawlink.ErrLinkFinished) {
+		t.Errorf("Send after Finish returned %v", err)
+	}
 
+	cl := rawlink.NewLink()
+	cl.Close()
+	if err := cl.Send(&sielink.Payload{}); !errors.Is(err, rawlink.ErrLinkClosed) {
+		t.Errorf("Send after Close returned %v", err)
+	}
+	http.Handle("/TestLinkErrors", websocket.Handler(
+		func(c *websocket.Conn) {
+			cl.HandleConnection(c)
+		}))
+	err := testDial(rawlink.NewLink(), serverURL+"/TestLinkErrors")
+	if !errors.Is(err, rawlink.ErrPeerUnavailable) || errors.Is(err, rawlink.ErrLinkClosed) {
+		t.Errorf("connection to closed Link returned %v", err)
+	}
+	var alert *sielink.Alert
+	if !errors.As(err, &alert) || alert.GetCode() != sielink.AlertCodeUnavailable {
+		t.Errorf("connection to closed Link returned alert %v", alert)
+	}
 }
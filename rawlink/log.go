@@ -0,0 +1,44 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package rawlink
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/farsightsec/sielink"
+)
+
+// discardHandler is the slog.Handler of the Link's default Logger, which
+// discards all records.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// log returns the Link's Logger annotated with the remote address of c.
+func (l *Link) log(c *Conn) *slog.Logger {
+	return l.Logger.With("remote", c.RemoteAddr())
+}
+
+// logAlert logs an alert sent or received on c.
+func (l *Link) logAlert(c *Conn, direction string, a *sielink.Alert, attrs ...any) {
+	level := slog.LevelWarn
+	if a.GetLevel() == sielink.AlertLevel_FatalError {
+		level = slog.LevelError
+	}
+	attrs = append([]any{
+		"level", a.GetLevel().String(),
+		"code", sielink.AlertCodeName(a.GetCode()),
+		"message", a.GetMessage(),
+	}, attrs...)
+	l.log(c).Log(context.Background(), level, direction+" alert", attrs...)
+}
@@ -30,14 +30,23 @@ func writeMessage(c *websocket.Conn, m *sielink.Message) error {
 	return websocket.Message.Send(c, b)
 }
 
-func writeAlert(c *websocket.Conn, err error) error {
-	return writeMessage(c, &sielink.Message{
+// writeAlert sends a fatal alert reporting err on c.
+func (l *Link) writeAlert(c *Conn, err error) error {
+	return l.sendAlert(c, &sielink.Alert{
+		Level:   sielink.AlertLevel_FatalError.Enum(),
+		Message: proto.String(err.Error()),
+		Code:    proto.Uint32(alertCode(err)),
+	})
+}
+
+// sendAlert sends alert on c, recording it in the Link's metrics and log.
+func (l *Link) sendAlert(c *Conn, alert *sielink.Alert) error {
+	l.metrics.alert("sent", alert.GetLevel())
+	l.logAlert(c, "sent", alert)
+	return writeMessage(c.ws, &sielink.Message{
 		ProtocolVersion: sielink.SupportedVersions,
 		MessageType:     sielink.MessageType_AlertMessage.Enum(),
-		Alert: &sielink.Alert{
-			Level:   sielink.AlertLevel_FatalError.Enum(),
-			Message: proto.String(err.Error()),
-		},
+		Alert:           alert,
 	})
 }
 
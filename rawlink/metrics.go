@@ -0,0 +1,160 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package rawlink
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/farsightsec/sielink"
+	"github.com/farsightsec/sielink/metrics"
+)
+
+// linkMetrics instruments a Link.
+type linkMetrics struct {
+	registry *metrics.Registry
+
+	payloadsSent, bytesSent         *metrics.CounterVec
+	payloadsReceived, bytesReceived *metrics.CounterVec
+	payloadsDropped                 *metrics.CounterVec
+	lossPayloads, lossBytes         *metrics.CounterVec
+	alerts                          *metrics.CounterVec
+	connections                     *metrics.GaugeVec
+	heartbeatLateness               *metrics.HistogramVec
+	writeLatency                    *metrics.HistogramVec
+	rtt                             *metrics.HistogramVec
+	clockOffset                     *metrics.GaugeVec
+
+	// peers counts the open connections to each peer, so that the
+	// peer's timing series are removed when its last connection closes.
+	peerMutex sync.Mutex
+	peers     map[string]int
+}
+
+func newLinkMetrics() *linkMetrics {
+	r := metrics.NewRegistry()
+	return &linkMetrics{
+		registry: r,
+		payloadsSent: r.NewCounterVec("sielink_payloads_sent_total",
+			"Payloads sent, by channel.", "channel"),
+		bytesSent: r.NewCounterVec("sielink_payload_bytes_sent_total",
+			"Payload data bytes sent, by channel.", "channel"),
+		payloadsReceived: r.NewCounterVec("sielink_payloads_received_total",
+			"Payloads received, by channel.", "channel"),
+		bytesReceived: r.NewCounterVec("sielink_payload_bytes_received_total",
+			"Payload data bytes received, by channel.", "channel"),
+		payloadsDropped: r.NewCounterVec("sielink_payloads_dropped_total",
+			"Payloads dropped by interceptors, by direction.", "direction"),
+		lossPayloads: r.NewCounterVec("sielink_loss_payloads_total",
+			"Payload losses reported in loss counters of received payloads.", "counter"),
+		lossBytes: r.NewCounterVec("sielink_loss_bytes_total",
+			"Byte losses reported in loss counters of received payloads.", "counter"),
+		alerts: r.NewCounterVec("sielink_alerts_total",
+			"Alerts sent and received, by direction and level.", "direction", "level"),
+		connections: r.NewGaugeVec("sielink_connections",
+			"Connections handled by the Link, by state.", "state"),
+		heartbeatLateness: r.NewHistogramVec("sielink_heartbeat_lateness_seconds",
+			"Time by which received heartbeats exceeded the announced interval.",
+			metrics.DefaultBuckets),
+		writeLatency: r.NewHistogramVec("sielink_write_latency_seconds",
+			"Time taken to write payload messages to a connection.",
+			metrics.DefaultBuckets),
+		rtt: r.NewHistogramVec("sielink_rtt_seconds",
+			"Round-trip times measured through heartbeats, by peer.",
+			metrics.DefaultBuckets, "peer"),
+		clockOffset: r.NewGaugeVec("sielink_clock_offset_seconds",
+			"Estimated offset of the peer's clock from the local clock, by peer.", "peer"),
+		peers: make(map[string]int),
+	}
+}
+
+// Metrics returns the registry of the Link's metrics, which may be served
+// to Prometheus as an http.Handler.
+func (l *Link) Metrics() *metrics.Registry {
+	return l.metrics.registry
+}
+
+func (m *linkMetrics) sent(p *sielink.Payload, start time.Time) {
+	m.writeLatency.With().Observe(time.Since(start).Seconds())
+	ch := strconv.FormatUint(uint64(p.GetChannel()), 10)
+	m.payloadsSent.With(ch).Inc()
+	m.bytesSent.With(ch).Add(float64(len(p.Data)))
+}
+
+func (m *linkMetrics) received(p *sielink.Payload) {
+	ch := strconv.FormatUint(uint64(p.GetChannel()), 10)
+	m.payloadsReceived.With(ch).Inc()
+	m.bytesReceived.With(ch).Add(float64(len(p.Data)))
+	if ll := p.GetLinkLoss(); ll != nil {
+		m.lossPayloads.With("link").Add(float64(ll.GetPayloads()))
+		m.lossBytes.With("link").Add(float64(ll.GetBytes()))
+	}
+	if pl := p.GetPathLoss(); pl != nil {
+		m.lossPayloads.With("path").Add(float64(pl.GetPayloads()))
+		m.lossBytes.With("path").Add(float64(pl.GetBytes()))
+	}
+}
+
+func (m *linkMetrics) dropped(direction string) {
+	m.payloadsDropped.With(direction).Inc()
+}
+
+func (m *linkMetrics) alert(direction string, level sielink.AlertLevel) {
+	m.alerts.With(direction, level.String()).Inc()
+}
+
+func (m *linkMetrics) stateChange(from, to ConnState) {
+	if from != StateClosed {
+		m.connections.With(from.String()).Dec()
+	}
+	if to != StateClosed {
+		m.connections.With(to.String()).Inc()
+	}
+}
+
+func (m *linkMetrics) heartbeat(lateness time.Duration) {
+	if lateness < 0 {
+		lateness = 0
+	}
+	m.heartbeatLateness.With().Observe(lateness.Seconds())
+}
+
+// addPeer records a connection to peer, allowing timing series to be
+// exported for it.
+func (m *linkMetrics) addPeer(peer string) {
+	m.peerMutex.Lock()
+	defer m.peerMutex.Unlock()
+	m.peers[peer]++
+}
+
+// removePeer records the end of a connection to peer, and removes the
+// peer's timing series if no connections to it remain.
+func (m *linkMetrics) removePeer(peer string) {
+	m.peerMutex.Lock()
+	defer m.peerMutex.Unlock()
+	if m.peers[peer]--; m.peers[peer] > 0 {
+		return
+	}
+	delete(m.peers, peer)
+	m.rtt.Delete(peer)
+	m.clockOffset.Delete(peer)
+}
+
+func (m *linkMetrics) timing(peer string, rtt, offset time.Duration) {
+	m.peerMutex.Lock()
+	defer m.peerMutex.Unlock()
+	// Measurements may be made as a connection closes, after its
+	// peer has been removed.
+	if m.peers[peer] == 0 {
+		return
+	}
+	m.rtt.With(peer).Observe(rtt.Seconds())
+	m.clockOffset.With(peer).Set(offset.Seconds())
+}
@@ -8,19 +8,7 @@
 
 package rawlink
 
-import (
-	"fmt"
-	"time"
-
-	"golang.org/x/net/websocket"
-	"github.com/farsightsec/sielink"
-)
-
-type recoverError struct{ rerr interface{} }
-
-func (r recoverError) Error() string {
-	return fmt.Sprintf("panic: %v", r.rerr)
-}
+import "github.com/farsightsec/sielink"
 
 // runReader is the main connection receiver loop.
 //
@@ -28,47 +16,64 @@ func (r recoverError) Error() string {
 // fatal Alert from its peer (which it returns), or receives a Finished message
 // from its peer, in which case it returns nil.
 //
-func (l *Link) runReader(c *websocket.Conn, rshut chan<- struct{}) (err error) {
+func (l *Link) runReader(c *Conn, rshut chan<- struct{}) (err error) {
 	defer func() {
 		// The l.ControlFunc call needs to be in this closure for
 		// changes to l.ControlFunc to take effect. Otherwise, only
 		// the value at the time runReader is started will be used.
-		l.TopologyFunc(c, nil)
+		l.receiveTopology(c, nil)
 		if r := recover(); r != nil {
-			err = recoverError{r}
+			err = &PanicError{r}
 		}
 	}()
 	defer l.readWg.Done()
+	defer l.flushAlerts(c)
 
 	m := new(sielink.Message)
 	for {
-		if err = readMessage(c, m); err != nil {
-			return err
+		if err = readMessage(c.ws, m); err != nil {
+			return l.readError(c, err)
 		}
 
 		if hb := m.GetHeartbeat(); hb > 0 {
-			hbDeadline := time.Duration(hb+hb/2) * time.Millisecond
-			c.SetReadDeadline(time.Now().Add(hbDeadline))
+			c.setHeartbeat(hb)
+			c.ws.SetReadDeadline(heartbeatDeadline(c.Heartbeat()))
+		}
+		if m.Timestamp != nil {
+			l.receiveTimestamp(c, m)
 		}
 
 		switch m.GetMessageType() {
+		case sielink.MessageType_Heartbeat:
+			l.metrics.heartbeat(c.receivedHeartbeat())
 		case sielink.MessageType_DataMessage:
-			l.recvPayload <- m.Payload
+			if err = l.receivePayload(c, m.Payload); err != nil {
+				return err
+			}
 		case sielink.MessageType_TopologyMessage:
-			l.TopologyFunc(c, m.GetTopology())
+			c.setRequestedHeartbeat(m.GetHeartbeat())
+			l.receiveTopology(c, m.GetTopology())
 		case sielink.MessageType_AlertMessage:
 			alert := m.GetAlert()
 			if alert == nil {
 				continue
 			}
-			if alert.GetLevel() == sielink.AlertLevel_FatalError {
-				return alert
+			if err = l.receiveAlert(c, alert); err != nil {
+				return err
 			}
-			l.AlertFunc(c, alert)
 		case sielink.MessageType_Finished:
+			c.setPeerState(PeerFinished)
+			l.log(c).Info("peer finished sending")
 			return nil
 		case sielink.MessageType_Shutdown:
-			rshut <- struct{}{}
+			c.setPeerState(PeerShutdown)
+			l.log(c).Info("peer requested shutdown")
+			l.ShutdownFunc(c)
+			select {
+			case rshut <- struct{}{}:
+			default:
+				// A shutdown has already been signaled.
+			}
 		}
 
 	}
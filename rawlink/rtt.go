@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package rawlink
+
+import (
+	"net"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/farsightsec/sielink"
+)
+
+// Each heartbeat carries the sender's clock as a timestamp, and echoes
+// the timestamp most recently received from the peer along with the time
+// elapsed since its receipt. From an echoed timestamp t1, the delay d and
+// the peer's timestamp t3, received at t4, the recipient computes:
+//
+//	rtt    = (t4 - t1) - d
+//	offset = ((t3 - d - t1) + (t3 - t4)) / 2
+//
+// Successive samples are smoothed with a gain of 1/rttGain. Peers which
+// do not support timestamps ignore them, and are never measured.
+const rttGain = 8
+
+// RTT returns the smoothed round-trip time of the connection, measured
+// through its heartbeats, or zero if it has not yet been measured.
+func (c *Conn) RTT() time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.rtt
+}
+
+// ClockOffset returns the smoothed estimate of the offset of the peer's
+// clock from the local clock, positive if the peer's clock is ahead. It
+// is valid only once RTT returns a non-zero value.
+func (c *Conn) ClockOffset() time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.clockOffset
+}
+
+// stampHeartbeat sets the timestamp of heartbeat m, and echoes the most
+// recent timestamp received from the peer, if it has not been echoed.
+func (c *Conn) stampHeartbeat(m *sielink.Message) {
+	now := time.Now()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	m.Timestamp = proto.Uint64(uint64(now.UnixMicro()))
+	if c.peerTimestamp != 0 {
+		m.EchoTimestamp = proto.Uint64(c.peerTimestamp)
+		m.EchoDelay = proto.Uint64(uint64(now.Sub(c.peerTimestampAt) / time.Microsecond))
+		c.peerTimestamp = 0
+	}
+}
+
+// receiveTimestamp records the timestamp of m for echoing, and updates
+// the connection's round-trip time and clock offset from its echoed
+// timestamp.
+func (l *Link) receiveTimestamp(c *Conn, m *sielink.Message) {
+	rtt, ok := c.measure(m)
+	if !ok {
+		return
+	}
+	srtt, offset := c.RTT(), c.ClockOffset()
+	l.metrics.timing(c.metricsPeer, rtt, offset)
+	l.checkClockSkew(c, srtt, offset)
+}
+
+// measure records the timestamp of m, and returns the round-trip time
+// sampled from its echoed timestamp, if any.
+func (c *Conn) measure(m *sielink.Message) (time.Duration, bool) {
+	now := time.Now()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.peerTimestamp = m.GetTimestamp()
+	c.peerTimestampAt = now
+	if m.EchoTimestamp == nil {
+		return 0, false
+	}
+
+	t1, t3, t4 := int64(m.GetEchoTimestamp()), int64(m.GetTimestamp()), now.UnixMicro()
+	d := int64(m.GetEchoDelay())
+	rtt := time.Duration(t4-t1-d) * time.Microsecond
+	if rtt < 0 {
+		// The local clock has been stepped since the echoed
+		// timestamp was sent.
+		return 0, false
+	}
+	if rtt < time.Microsecond {
+		rtt = time.Microsecond
+	}
+	offset := time.Duration(((t3-d-t1)+(t3-t4))/2) * time.Microsecond
+
+	if c.rtt == 0 {
+		c.rtt, c.clockOffset = rtt, offset
+	} else {
+		c.rtt += (rtt - c.rtt) / rttGain
+		c.clockOffset += (offset - c.clockOffset) / rttGain
+	}
+	return rtt, true
+}
+
+// peerLabel identifies the peer of c in metrics: by its principal if
+// authenticated by a TLS client certificate, otherwise by its host.
+func (c *Conn) peerLabel() string {
+	if p := c.Principal(); p != "" {
+		return p
+	}
+	addr := c.RemoteAddr()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -8,41 +8,20 @@
 
 package rawlink
 
-import (
-	"time"
+import "github.com/farsightsec/sielink"
 
-	"github.com/golang/protobuf/proto"
-	"golang.org/x/net/websocket"
-	"github.com/farsightsec/sielink"
-)
-
-func sendHeartbeat(c *websocket.Conn, d time.Duration) {
-	if d == 0 {
-		return
-	}
-	ms := uint32(d / time.Millisecond)
-	heartbeatMessage := &sielink.Message{
-		ProtocolVersion: sielink.SupportedVersions,
-		MessageType:     sielink.MessageType_Heartbeat.Enum(),
-		Heartbeat:       proto.Uint32(ms),
-	}
-	t := time.NewTicker(d)
-	defer t.Stop()
+// sendConfigMessage sends each update of the Link's configuration to c
+// until the connection ends.
+func (l *Link) sendConfigMessage(c *Conn, upd <-chan struct{}) {
+	var m *sielink.Message
 	for {
-		c.SetWriteDeadline(time.Now().Add(d + d/2))
-		if err := writeMessage(c, heartbeatMessage); err != nil {
+		select {
+		case <-upd:
+		case <-c.done:
 			return
 		}
-		<-t.C
-	}
-}
-
-func (l *Link) sendConfigMessage(c *websocket.Conn, upd <-chan struct{}) {
-	var m *sielink.Message
-	for {
-		<-upd
 		m, upd = l.linkConfigMessage()
-		if err := writeMessage(c, m); err != nil {
+		if err := writeMessage(c.ws, m); err != nil {
 			return
 		}
 	}
@@ -50,16 +29,16 @@ func (l *Link) sendConfigMessage(c *websocket.Conn, upd <-chan struct{}) {
 
 // runSender is the main sender loop for the connection. It runs
 // in parallel with sendConfigMessage and sendHeartbeat.
-func (l *Link) runSender(c *websocket.Conn, receiveError <-chan error,
+func (l *Link) runSender(c *Conn, receiveError <-chan error,
 	receiveShutdown <-chan struct{}) (err error) {
 
 	for {
 		select {
 		case p, ok := <-l.sendPayload:
 			if !ok {
-				return finishConnection(c, receiveError)
+				return l.finishConnection(c, receiveError)
 			}
-			if err = writePayload(c, p); err != nil {
+			if err = l.writePayload(c, p); err != nil {
 				return err
 			}
 		case <-l.closed:
@@ -67,7 +46,7 @@ func (l *Link) runSender(c *websocket.Conn, receiveError <-chan error,
 		case <-l.shutdown:
 			return l.shutdownConnection(c, receiveError)
 		case <-receiveShutdown:
-			return finishConnection(c, receiveError)
+			return l.finishConnection(c, receiveError)
 		case err = <-receiveError:
 			if err != nil {
 				return
@@ -82,23 +61,25 @@ func (l *Link) runSender(c *websocket.Conn, receiveError <-chan error,
 }
 
 // shutDownConnection runs the sender side of a connection which has
-// requested a shutdown. It continues sending data until l.Finish() is
-// called, or a receive error occurs.
-func (l *Link) shutdownConnection(c *websocket.Conn, ech <-chan error) error {
+// requested a shutdown. It continues sending data until l.Finish() or
+// l.Close() is called, or a receive error occurs.
+func (l *Link) shutdownConnection(c *Conn, ech <-chan error) error {
 	shutdownMessage := &sielink.Message{
 		ProtocolVersion: sielink.SupportedVersions,
 		MessageType:     sielink.MessageType_Shutdown.Enum(),
 	}
-	if err := writeMessage(c, shutdownMessage); err != nil {
+	l.setState(c, StateDraining, nil)
+	l.log(c).Info("requesting peer shutdown")
+	if err := writeMessage(c.ws, shutdownMessage); err != nil {
 		return err
 	}
 	for {
 		select {
 		case p, ok := <-l.sendPayload:
 			if !ok {
-				return finishConnection(c, ech)
+				return l.finishConnection(c, ech)
 			}
-			if err := writePayload(c, p); err != nil {
+			if err := l.writePayload(c, p); err != nil {
 				return err
 			}
 		case err := <-ech:
@@ -106,6 +87,8 @@ func (l *Link) shutdownConnection(c *websocket.Conn, ech <-chan error) error {
 				return err
 			}
 			ech = nil
+		case <-l.closed:
+			return nil
 		}
 	}
 }
@@ -113,18 +96,25 @@ func (l *Link) shutdownConnection(c *websocket.Conn, ech <-chan error) error {
 // finish Connection sends a Finished message, then waits for the
 // receiver goroutine to finish. If it has already finished, ech
 // will be nil, and finishConnection will return immediately after
-// sending the Finished message.
-func finishConnection(c *websocket.Conn, ech <-chan error) error {
+// sending the Finished message. It also returns if the Link is closed.
+func (l *Link) finishConnection(c *Conn, ech <-chan error) error {
 	finishedMessage := &sielink.Message{
 		ProtocolVersion: sielink.SupportedVersions,
 		MessageType:     sielink.MessageType_Finished.Enum(),
 	}
+	l.setState(c, StateDraining, nil)
+	l.log(c).Info("finished sending")
 
-	if err := writeMessage(c, finishedMessage); err != nil {
+	if err := writeMessage(c.ws, finishedMessage); err != nil {
 		return err
 	}
 	if ech == nil {
 		return nil
 	}
-	return <-ech
+	select {
+	case err := <-ech:
+		return err
+	case <-l.closed:
+		return nil
+	}
 }
@@ -0,0 +1,67 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package rawlink
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/farsightsec/sielink"
+)
+
+// ClockSkewed reports whether the offset of the peer's clock currently
+// exceeds the Link's ClockSkewThreshold.
+func (c *Conn) ClockSkewed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.clockSkewed
+}
+
+// checkClockSkew compares the smoothed clock offset of c with the Link's
+// ClockSkewThreshold. When the offset first exceeds the threshold by more
+// than its uncertainty of half the round-trip time, the peer is sent a
+// Warning alert and l.ClockSkewFunc is called. The condition clears once
+// the offset falls within the threshold.
+func (l *Link) checkClockSkew(c *Conn, rtt, offset time.Duration) {
+	threshold := l.ClockSkewThreshold
+	if threshold <= 0 {
+		return
+	}
+	abs := offset
+	if abs < 0 {
+		abs = -abs
+	}
+
+	c.mutex.Lock()
+	prev := c.clockSkewed
+	if prev {
+		c.clockSkewed = abs > threshold
+	} else {
+		c.clockSkewed = abs > threshold+rtt/2
+	}
+	skewed := c.clockSkewed
+	c.mutex.Unlock()
+
+	switch {
+	case skewed == prev:
+	case skewed:
+		l.log(c).Warn("peer clock skewed", "offset", offset, "threshold", threshold)
+		l.sendAlert(c, &sielink.Alert{
+			Level: sielink.AlertLevel_Warning.Enum(),
+			Message: proto.String(fmt.Sprintf("clock offset %v exceeds %v",
+				-offset, threshold)),
+			Code: proto.Uint32(sielink.AlertCodeClockSkew),
+		})
+		l.ClockSkewFunc(c, offset)
+	default:
+		l.log(c).Info("peer clock skew cleared", "offset", offset)
+	}
+}
@@ -10,26 +10,37 @@ package rawlink
 
 import (
 	"fmt"
-	"time"
 
-	"golang.org/x/net/websocket"
 	"github.com/farsightsec/sielink"
 )
 
-func (l *Link) runConnection(c *websocket.Conn) (err error) {
-	defer c.Close()
+func (l *Link) runConnection(c *Conn) (err error) {
+	defer c.ws.Close()
+	defer close(c.done)
+	// HandleConnection counts the connection's reader in l.readWg when
+	// accepting it, so the count must be released if the reader never
+	// starts.
+	reading := false
+	defer func() {
+		if !reading {
+			l.readWg.Done()
+		}
+	}()
+	l.setState(c, StateHandshaking, nil)
+	l.log(c).Debug("handshake started")
+	c.setLocalHeartbeat(l.linkHeartbeat())
 
 	localConfig, configUpdate := l.linkConfigMessage()
 
-	if err = writeMessage(c, localConfig); err != nil {
+	if err = writeMessage(c.ws, localConfig); err != nil {
 		return
 	}
 
 	remoteConfig := new(sielink.Message)
 
 	// read remote config message
-	if err = readMessage(c, remoteConfig); err != nil {
-		return
+	if err = readMessage(c.ws, remoteConfig); err != nil {
+		return l.readError(c, err)
 	}
 
 	// check version, etc. from config message
@@ -37,6 +48,7 @@ func (l *Link) runConnection(c *websocket.Conn) (err error) {
 	if err != nil {
 		return err
 	}
+	c.setVersion(remoteVersion)
 
 	// Placeholder for future protocol fall-back.
 	switch remoteVersion {
@@ -44,16 +56,19 @@ func (l *Link) runConnection(c *websocket.Conn) (err error) {
 	default:
 	}
 
-	go l.sendConfigMessage(c, configUpdate)
-	go sendHeartbeat(c, l.Heartbeat)
+	l.setState(c, StateEstablished, nil)
+	l.log(c).Info("connection established", "version", remoteVersion,
+		"heartbeat", c.NegotiatedHeartbeat())
+	l.spawn(func() { l.sendConfigMessage(c, configUpdate) })
+	l.spawn(func() { l.sendHeartbeat(c) })
 
 	receiveShutdown := make(chan struct{}, 1)
 	receiveError := make(chan error, 1)
 
-	l.readWg.Add(1)
-	go func() {
+	reading = true
+	l.spawn(func() {
 		receiveError <- l.runReader(c, receiveShutdown)
-	}()
+	})
 
 	return l.runSender(c, receiveError, receiveShutdown)
 }
@@ -69,33 +84,36 @@ func matchVersion(v []uint32) (max uint32) {
 	return
 }
 
-func (l *Link) processConfig(c *websocket.Conn, m *sielink.Message) (uint32, error) {
+func (l *Link) processConfig(c *Conn, m *sielink.Message) (uint32, error) {
 	mv := m.GetProtocolVersion()
 	v := matchVersion(mv)
 	if v == 0 {
-		err := fmt.Errorf("Versions %v not supported", mv)
-		writeAlert(c, err)
+		err := &VersionError{Remote: mv, Supported: sielink.SupportedVersions}
+		l.log(c).Warn("protocol version mismatch", "remote_versions", mv,
+			"supported", sielink.SupportedVersions)
+		l.writeAlert(c, err)
 		return 0, err
 	}
 
 	if m.GetHeartbeat() > 0 {
-		d := time.Millisecond * time.Duration(m.GetHeartbeat())
-		c.SetReadDeadline(time.Now().Add(d))
+		c.setHeartbeat(m.GetHeartbeat())
+		c.ws.SetReadDeadline(heartbeatDeadline(c.Heartbeat()))
 	}
 
 	switch m.GetMessageType() {
 	case sielink.MessageType_Heartbeat:
+		c.receivedHeartbeat()
 	case sielink.MessageType_TopologyMessage:
-		l.TopologyFunc(c, m.GetTopology())
+		c.setRequestedHeartbeat(m.GetHeartbeat())
+		l.receiveTopology(c, m.GetTopology())
 	case sielink.MessageType_AlertMessage:
 		alert := m.GetAlert()
-		if alert.GetLevel() == sielink.AlertLevel_FatalError {
-			return 0, alert
+		if err := l.receiveAlert(c, alert); err != nil {
+			return 0, err
 		}
-		l.AlertFunc(c, alert)
 	default:
-		err := fmt.Errorf("Unexpected message type %s", m.GetMessageType())
-		writeAlert(c, err)
+		err := &ProtocolError{fmt.Sprintf("Unexpected message type %s", m.GetMessageType())}
+		l.writeAlert(c, err)
 		return v, err
 	}
 	return v, nil
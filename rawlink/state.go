@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package rawlink
+
+// ConnState describes the lifecycle state of a Link connection.
+type ConnState int
+
+const (
+	// StateClosed connections have ended.
+	StateClosed ConnState = iota
+	// StateDialing connections are being established by a profile,
+	// and have not yet been passed to HandleConnection.
+	StateDialing
+	// StateHandshaking connections are exchanging their initial
+	// configuration messages.
+	StateHandshaking
+	// StateEstablished connections have completed the handshake and
+	// are exchanging data.
+	StateEstablished
+	// StateDraining connections have sent or received a Shutdown or
+	// Finished message, and are completing outstanding sends before
+	// closing.
+	StateDraining
+)
+
+var connStateNames = map[ConnState]string{
+	StateClosed:      "closed",
+	StateDialing:     "dialing",
+	StateHandshaking: "handshaking",
+	StateEstablished: "established",
+	StateDraining:    "draining",
+}
+
+func (s ConnState) String() string {
+	if name, ok := connStateNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
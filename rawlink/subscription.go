@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2017 by Farsight Security, Inc.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package rawlink
+
+import (
+	"github.com/golang/protobuf/proto"
+
+	"github.com/farsightsec/sielink"
+)
+
+// receiveTopology records the subscriptions in a topology message
+// received on c, and passes the message to l.TopologyFunc. A nil
+// topology indicates the connection has closed.
+//
+// If the topology carries a new subscription and l.SubscriptionFunc
+// is set, receiveTopology acknowledges the subscription.
+func (l *Link) receiveTopology(c *Conn, t *sielink.Topology) {
+	ack := false
+	if t != nil {
+		ack = c.setTopology(t) && l.SubscriptionFunc != nil
+	}
+
+	if a := t.GetSubscriptionAck(); a != nil {
+		l.SubscriptionAckFunc(c, a)
+	}
+	l.TopologyFunc(c, t)
+	if ack {
+		l.sendSubscriptionAck(c, t)
+	}
+}
+
+// sendSubscriptionAck sends the local configuration to c, together with
+// an acknowledgment of the subscription in t.
+func (l *Link) sendSubscriptionAck(c *Conn, t *sielink.Topology) error {
+	accepted, rejected := l.SubscriptionFunc(c, t.GetSubscription())
+	config, _ := l.linkConfigMessage()
+	m := proto.Clone(config).(*sielink.Message)
+	m.Topology.SubscriptionAck = &sielink.SubscriptionAck{
+		Serial:   t.SubscriptionSerial,
+		Accepted: accepted,
+		Rejected: rejected,
+	}
+	return writeMessage(c.ws, m)
+}
+
+// Subscribed reports whether any peer connected to the Link has
+// subscribed to the supplied payload, either by channel, by a channel
+// range, or by a subscription to all channels.
+func (l *Link) Subscribed(p *sielink.Payload) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for c := range l.conns {
+		if sielink.MatchSubscriptions(c.Topology().GetSubscription(), p) {
+			return true
+		}
+	}
+	return false
+}
@@ -90,3 +90,36 @@ func (p *Path) GetNexthop() uint32 {
 	}
 	return 0
 }
+
+// Matches reports whether the Subscription requests the supplied Payload.
+func (s *Subscription) Matches(p *Payload) bool {
+	if site := s.GetSourceSite(); site != 0 && site != p.GetSourceSite() {
+		return false
+	}
+	if s.GetAllChannels() {
+		return true
+	}
+	ch := p.GetChannel()
+	for _, c := range s.Channel {
+		if c == ch {
+			return true
+		}
+	}
+	for _, r := range s.Range {
+		if ch >= r.GetFirst() && ch <= r.GetLast() {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchSubscriptions reports whether any of the supplied Subscriptions
+// requests the Payload.
+func MatchSubscriptions(subs []*Subscription, p *Payload) bool {
+	for _, s := range subs {
+		if s.Matches(p) {
+			return true
+		}
+	}
+	return false
+}
@@ -13,8 +13,10 @@ It has these top-level messages:
 	Payload
 	LossCounter
 	Topology
+	SubscriptionAck
 	Path
 	Subscription
+	ChannelRange
 	Alert
 */
 package sielink
@@ -204,6 +206,9 @@ type Message struct {
 	Topology         *Topology    `protobuf:"bytes,4,opt,name=topology" json:"topology,omitempty"`
 	Heartbeat        *uint32      `protobuf:"varint,5,opt,name=heartbeat" json:"heartbeat,omitempty"`
 	Alert            *Alert       `protobuf:"bytes,6,opt,name=alert" json:"alert,omitempty"`
+	Timestamp        *uint64      `protobuf:"varint,7,opt,name=timestamp" json:"timestamp,omitempty"`
+	EchoTimestamp    *uint64      `protobuf:"varint,8,opt,name=echoTimestamp" json:"echoTimestamp,omitempty"`
+	EchoDelay        *uint64      `protobuf:"varint,9,opt,name=echoDelay" json:"echoDelay,omitempty"`
 	XXX_unrecognized []byte       `json:"-"`
 }
 
@@ -254,6 +259,27 @@ func (m *Message) GetAlert() *Alert {
 	return nil
 }
 
+func (m *Message) GetTimestamp() uint64 {
+	if m != nil && m.Timestamp != nil {
+		return *m.Timestamp
+	}
+	return 0
+}
+
+func (m *Message) GetEchoTimestamp() uint64 {
+	if m != nil && m.EchoTimestamp != nil {
+		return *m.EchoTimestamp
+	}
+	return 0
+}
+
+func (m *Message) GetEchoDelay() uint64 {
+	if m != nil && m.EchoDelay != nil {
+		return *m.EchoDelay
+	}
+	return 0
+}
+
 type Payload struct {
 	Channel           *uint32          `protobuf:"varint,1,req,name=channel" json:"channel,omitempty"`
 	PayloadType       *PayloadType     `protobuf:"varint,2,opt,name=payloadType,enum=sielink.PayloadType" json:"payloadType,omitempty"`
@@ -353,9 +379,11 @@ func (m *LossCounter) GetPayloads() uint64 {
 }
 
 type Topology struct {
-	Path             []*Path         `protobuf:"bytes,1,rep,name=path" json:"path,omitempty"`
-	Subscription     []*Subscription `protobuf:"bytes,2,rep,name=subscription" json:"subscription,omitempty"`
-	XXX_unrecognized []byte          `json:"-"`
+	Path               []*Path          `protobuf:"bytes,1,rep,name=path" json:"path,omitempty"`
+	Subscription       []*Subscription  `protobuf:"bytes,2,rep,name=subscription" json:"subscription,omitempty"`
+	SubscriptionSerial *uint32          `protobuf:"varint,3,opt,name=subscriptionSerial" json:"subscriptionSerial,omitempty"`
+	SubscriptionAck    *SubscriptionAck `protobuf:"bytes,4,opt,name=subscriptionAck" json:"subscriptionAck,omitempty"`
+	XXX_unrecognized   []byte           `json:"-"`
 }
 
 func (m *Topology) Reset()                    { *m = Topology{} }
@@ -377,6 +405,53 @@ func (m *Topology) GetSubscription() []*Subscription {
 	return nil
 }
 
+func (m *Topology) GetSubscriptionSerial() uint32 {
+	if m != nil && m.SubscriptionSerial != nil {
+		return *m.SubscriptionSerial
+	}
+	return 0
+}
+
+func (m *Topology) GetSubscriptionAck() *SubscriptionAck {
+	if m != nil {
+		return m.SubscriptionAck
+	}
+	return nil
+}
+
+type SubscriptionAck struct {
+	Serial           *uint32         `protobuf:"varint,1,opt,name=serial" json:"serial,omitempty"`
+	Accepted         []*Subscription `protobuf:"bytes,2,rep,name=accepted" json:"accepted,omitempty"`
+	Rejected         []*Subscription `protobuf:"bytes,3,rep,name=rejected" json:"rejected,omitempty"`
+	XXX_unrecognized []byte          `json:"-"`
+}
+
+func (m *SubscriptionAck) Reset()                    { *m = SubscriptionAck{} }
+func (m *SubscriptionAck) String() string            { return proto.CompactTextString(m) }
+func (*SubscriptionAck) ProtoMessage()               {}
+func (*SubscriptionAck) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *SubscriptionAck) GetSerial() uint32 {
+	if m != nil && m.Serial != nil {
+		return *m.Serial
+	}
+	return 0
+}
+
+func (m *SubscriptionAck) GetAccepted() []*Subscription {
+	if m != nil {
+		return m.Accepted
+	}
+	return nil
+}
+
+func (m *SubscriptionAck) GetRejected() []*Subscription {
+	if m != nil {
+		return m.Rejected
+	}
+	return nil
+}
+
 type Path struct {
 	Metric           *uint64  `protobuf:"varint,1,req,name=metric" json:"metric,omitempty"`
 	Site             []uint32 `protobuf:"varint,2,rep,name=site" json:"site,omitempty"`
@@ -386,7 +461,7 @@ type Path struct {
 func (m *Path) Reset()                    { *m = Path{} }
 func (m *Path) String() string            { return proto.CompactTextString(m) }
 func (*Path) ProtoMessage()               {}
-func (*Path) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (*Path) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
 
 func (m *Path) GetMetric() uint64 {
 	if m != nil && m.Metric != nil {
@@ -403,15 +478,17 @@ func (m *Path) GetSite() []uint32 {
 }
 
 type Subscription struct {
-	SourceSite       *uint32  `protobuf:"varint,1,opt,name=sourceSite" json:"sourceSite,omitempty"`
-	Channel          []uint32 `protobuf:"varint,2,rep,name=channel" json:"channel,omitempty"`
-	XXX_unrecognized []byte   `json:"-"`
+	SourceSite       *uint32         `protobuf:"varint,1,opt,name=sourceSite" json:"sourceSite,omitempty"`
+	Channel          []uint32        `protobuf:"varint,2,rep,name=channel" json:"channel,omitempty"`
+	Range            []*ChannelRange `protobuf:"bytes,3,rep,name=range" json:"range,omitempty"`
+	AllChannels      *bool           `protobuf:"varint,4,opt,name=allChannels" json:"allChannels,omitempty"`
+	XXX_unrecognized []byte          `json:"-"`
 }
 
 func (m *Subscription) Reset()                    { *m = Subscription{} }
 func (m *Subscription) String() string            { return proto.CompactTextString(m) }
 func (*Subscription) ProtoMessage()               {}
-func (*Subscription) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*Subscription) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
 
 func (m *Subscription) GetSourceSite() uint32 {
 	if m != nil && m.SourceSite != nil {
@@ -427,6 +504,45 @@ func (m *Subscription) GetChannel() []uint32 {
 	return nil
 }
 
+func (m *Subscription) GetRange() []*ChannelRange {
+	if m != nil {
+		return m.Range
+	}
+	return nil
+}
+
+func (m *Subscription) GetAllChannels() bool {
+	if m != nil && m.AllChannels != nil {
+		return *m.AllChannels
+	}
+	return false
+}
+
+type ChannelRange struct {
+	First            *uint32 `protobuf:"varint,1,req,name=first" json:"first,omitempty"`
+	Last             *uint32 `protobuf:"varint,2,req,name=last" json:"last,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *ChannelRange) Reset()                    { *m = ChannelRange{} }
+func (m *ChannelRange) String() string            { return proto.CompactTextString(m) }
+func (*ChannelRange) ProtoMessage()               {}
+func (*ChannelRange) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *ChannelRange) GetFirst() uint32 {
+	if m != nil && m.First != nil {
+		return *m.First
+	}
+	return 0
+}
+
+func (m *ChannelRange) GetLast() uint32 {
+	if m != nil && m.Last != nil {
+		return *m.Last
+	}
+	return 0
+}
+
 type Alert struct {
 	Level            *AlertLevel `protobuf:"varint,1,req,name=level,enum=sielink.AlertLevel" json:"level,omitempty"`
 	Message          *string     `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
@@ -437,7 +553,7 @@ type Alert struct {
 func (m *Alert) Reset()                    { *m = Alert{} }
 func (m *Alert) String() string            { return proto.CompactTextString(m) }
 func (*Alert) ProtoMessage()               {}
-func (*Alert) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+func (*Alert) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
 
 func (m *Alert) GetLevel() AlertLevel {
 	if m != nil && m.Level != nil {
@@ -465,8 +581,10 @@ func init() {
 	proto.RegisterType((*Payload)(nil), "sielink.Payload")
 	proto.RegisterType((*LossCounter)(nil), "sielink.LossCounter")
 	proto.RegisterType((*Topology)(nil), "sielink.Topology")
+	proto.RegisterType((*SubscriptionAck)(nil), "sielink.SubscriptionAck")
 	proto.RegisterType((*Path)(nil), "sielink.Path")
 	proto.RegisterType((*Subscription)(nil), "sielink.Subscription")
+	proto.RegisterType((*ChannelRange)(nil), "sielink.ChannelRange")
 	proto.RegisterType((*Alert)(nil), "sielink.Alert")
 	proto.RegisterEnum("sielink.MessageType", MessageType_name, MessageType_value)
 	proto.RegisterEnum("sielink.PayloadType", PayloadType_name, PayloadType_value)
@@ -477,49 +595,60 @@ func init() {
 func init() { proto.RegisterFile("sielink.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 697 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x7c, 0x53, 0x5d, 0x6f, 0xd3, 0x3c,
-	0x14, 0x7e, 0x9b, 0xa4, 0x4b, 0x77, 0xd2, 0x8f, 0xcc, 0xdb, 0x8b, 0x22, 0x84, 0xd0, 0x88, 0xb8,
-	0x28, 0x15, 0x4c, 0xa8, 0x42, 0x48, 0x88, 0x0b, 0x34, 0xf6, 0xc1, 0x90, 0xba, 0x69, 0xf2, 0x26,
-	0x90, 0xb8, 0xc2, 0x4d, 0xbd, 0x26, 0x22, 0x8d, 0x23, 0xdb, 0x1d, 0x2a, 0x3f, 0x81, 0xff, 0xc9,
-	0xff, 0xc0, 0x76, 0x3e, 0x9a, 0x76, 0x12, 0x77, 0x3e, 0xe7, 0x3c, 0xe7, 0xf1, 0xf1, 0xe3, 0xe7,
-	0x40, 0x4f, 0x24, 0x34, 0x4d, 0xb2, 0x1f, 0x47, 0x39, 0x67, 0x92, 0x21, 0xb7, 0x0c, 0xc3, 0xdf,
-	0x16, 0xb8, 0x97, 0x54, 0x08, 0x32, 0xa7, 0x68, 0x08, 0x03, 0x53, 0x8d, 0x58, 0xfa, 0x85, 0x72,
-	0x91, 0xb0, 0x2c, 0x68, 0x1d, 0xda, 0xc3, 0x1e, 0xde, 0x4e, 0xa3, 0xb7, 0xe0, 0x2d, 0x8a, 0xa6,
-	0xdb, 0x55, 0x4e, 0x03, 0xeb, 0xd0, 0x1a, 0xf6, 0xc7, 0x07, 0x47, 0xd5, 0x1d, 0x97, 0xeb, 0x1a,
-	0x6e, 0x02, 0xd1, 0x08, 0xdc, 0x9c, 0xac, 0x52, 0x46, 0x66, 0x81, 0x7d, 0xd8, 0x1a, 0x7a, 0x63,
-	0xbf, 0xee, 0xb9, 0x2e, 0xf2, 0xb8, 0x02, 0xa0, 0x57, 0xd0, 0x91, 0x2c, 0x67, 0x29, 0x9b, 0xaf,
-	0x02, 0xc7, 0x80, 0xf7, 0x6a, 0xf0, 0x6d, 0x59, 0xc0, 0x35, 0x04, 0x3d, 0x81, 0xdd, 0x98, 0x12,
-	0x2e, 0xa7, 0x94, 0xc8, 0xa0, 0xad, 0xf0, 0x3d, 0xbc, 0x4e, 0xa0, 0xe7, 0xd0, 0x26, 0x29, 0xe5,
-	0x32, 0xd8, 0x31, 0x4c, 0xfd, 0x9a, 0xe9, 0x58, 0x67, 0x71, 0x51, 0x0c, 0xff, 0x28, 0x31, 0xca,
-	0x39, 0x50, 0x00, 0x6e, 0x14, 0x93, 0x2c, 0xa3, 0xa9, 0x12, 0xc1, 0x52, 0x6c, 0x55, 0xa8, 0x1f,
-	0x5f, 0xce, 0x58, 0x3e, 0xbe, 0xb5, 0xf1, 0xf8, 0xeb, 0x75, 0x0d, 0x37, 0x81, 0xe8, 0x23, 0x0c,
-	0x22, 0xb6, 0xc8, 0xb9, 0xd2, 0x43, 0x69, 0x68, 0x7a, 0x6d, 0xd3, 0x1b, 0xd4, 0xbd, 0x27, 0x9b,
-	0x75, 0xbc, 0xdd, 0x80, 0x10, 0x38, 0x33, 0x22, 0x89, 0x11, 0xa4, 0x8b, 0xcd, 0x19, 0xbd, 0x86,
-	0x8e, 0x6e, 0x9e, 0x30, 0x21, 0xcc, 0xc3, 0xbd, 0xc6, 0x30, 0x3a, 0x79, 0xc2, 0x96, 0x99, 0xa4,
-	0x1c, 0xd7, 0x28, 0xdd, 0x91, 0x13, 0x19, 0x9b, 0x8e, 0x9d, 0x7f, 0x75, 0x54, 0x28, 0xf4, 0x14,
-	0x40, 0xb0, 0x25, 0x8f, 0xe8, 0x4d, 0x22, 0x69, 0xe0, 0x1a, 0x79, 0x1b, 0x19, 0xf4, 0x12, 0xf6,
-	0x8a, 0xe8, 0x84, 0x65, 0x92, 0x27, 0xd3, 0xa5, 0x64, 0x3c, 0xe8, 0x18, 0xd8, 0xc3, 0x42, 0xf8,
-	0x01, 0xbc, 0xc6, 0x35, 0xe8, 0x00, 0xda, 0xd3, 0x95, 0xa4, 0x42, 0x09, 0xdd, 0x1a, 0x3a, 0xb8,
-	0x08, 0xd0, 0x63, 0x3d, 0xa4, 0x51, 0x4f, 0x18, 0x8d, 0x1d, 0x5c, 0xc7, 0x61, 0x0c, 0x9d, 0xca,
-	0x02, 0xe8, 0x19, 0x38, 0x7a, 0x4c, 0x63, 0x55, 0x6f, 0xdc, 0x6b, 0xfc, 0x83, 0x8c, 0xb1, 0x29,
-	0xa1, 0x77, 0xd0, 0x15, 0xcb, 0xa9, 0x88, 0x78, 0x92, 0x4b, 0xed, 0x6a, 0xcb, 0x40, 0xff, 0xaf,
-	0xa1, 0x37, 0x8d, 0x22, 0xde, 0x80, 0x86, 0x63, 0x70, 0x34, 0x11, 0x7a, 0x04, 0x3b, 0x0b, 0xaa,
-	0x1e, 0x10, 0x19, 0x37, 0x38, 0xb8, 0x8c, 0xf4, 0x87, 0x08, 0x2d, 0x89, 0x65, 0x16, 0xc5, 0x9c,
-	0xc3, 0x0b, 0xe8, 0x36, 0x19, 0xb7, 0xc4, 0x6b, 0x3d, 0x10, 0xaf, 0x61, 0xb5, 0x82, 0xa6, 0x0a,
-	0xc3, 0xef, 0xd0, 0x36, 0x06, 0x45, 0x2f, 0xa0, 0x9d, 0xd2, 0xfb, 0xd2, 0x8b, 0xfd, 0xf1, 0xfe,
-	0xa6, 0x7f, 0x27, 0xba, 0x84, 0x0b, 0x84, 0x66, 0x2b, 0x57, 0xce, 0xc8, 0xb6, 0x8b, 0xab, 0x50,
-	0xcf, 0x1a, 0xb1, 0x59, 0xe1, 0x3a, 0x35, 0xab, 0x3e, 0x8f, 0x72, 0xf0, 0x1a, 0xdb, 0x8a, 0x06,
-	0xe0, 0x9d, 0x2a, 0x4f, 0x95, 0x29, 0xff, 0x3f, 0xb4, 0x0f, 0x83, 0x4a, 0xe9, 0x2a, 0xd9, 0x42,
-	0x3e, 0x74, 0xcd, 0xbd, 0x55, 0xc6, 0x42, 0x3d, 0xd8, 0xbd, 0xa8, 0x96, 0xcd, 0xb7, 0x51, 0x17,
-	0x3a, 0x37, 0xf1, 0x52, 0xce, 0xd8, 0xcf, 0xcc, 0x77, 0x74, 0x74, 0x9e, 0x64, 0x89, 0x88, 0xe9,
-	0xcc, 0x6f, 0x8f, 0xce, 0xc0, 0x6b, 0xac, 0x08, 0xda, 0x83, 0xde, 0xd5, 0x42, 0xcc, 0xb5, 0x3d,
-	0x48, 0x92, 0x51, 0xae, 0xe8, 0x15, 0xd9, 0x84, 0xcd, 0x31, 0x8d, 0x18, 0x9f, 0x29, 0xee, 0x03,
-	0xf0, 0x8f, 0xa3, 0x48, 0x7b, 0x25, 0xc9, 0xaa, 0xac, 0x3d, 0x7a, 0x0f, 0x83, 0xad, 0x6d, 0x41,
-	0x1d, 0x70, 0xae, 0x58, 0xa6, 0xa7, 0x56, 0xa7, 0x4f, 0xbf, 0x92, 0x5c, 0x71, 0x79, 0xe0, 0x9e,
-	0xd2, 0xbb, 0x94, 0x48, 0x3d, 0xa5, 0x0b, 0xf6, 0xe4, 0xdb, 0x1b, 0xd5, 0x8c, 0x01, 0xd6, 0xc2,
-	0xe9, 0x11, 0x3e, 0x67, 0x77, 0x8c, 0x2f, 0x88, 0xfe, 0x2e, 0x92, 0x2a, 0x02, 0xd5, 0xf6, 0x95,
-	0xf0, 0x4c, 0x5d, 0xa8, 0x38, 0xd4, 0x00, 0xfa, 0xda, 0x7b, 0xca, 0xc9, 0x34, 0xa5, 0x67, 0x9c,
-	0x33, 0xae, 0xc8, 0xfa, 0x00, 0xe7, 0x4a, 0xaa, 0xb4, 0x88, 0xed, 0xbf, 0x01, 0x00, 0x00, 0xff,
-	0xff, 0x86, 0x25, 0x97, 0x29, 0x62, 0x05, 0x00, 0x00,
+	// 868 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x54, 0x5f, 0x6f, 0xe3, 0x44,
+	0x10, 0x3f, 0x3b, 0x4e, 0xe3, 0x8e, 0xe3, 0xc6, 0x9d, 0x2b, 0xc8, 0x42, 0x08, 0x05, 0xeb, 0x1e,
+	0x42, 0x80, 0x0a, 0x22, 0x84, 0x40, 0x3c, 0xa0, 0xd2, 0xde, 0x01, 0x52, 0xef, 0x74, 0xda, 0x56,
+	0x20, 0xf1, 0xc4, 0xc6, 0xd9, 0x26, 0xa6, 0x8e, 0xd7, 0xda, 0xdd, 0x1c, 0x0a, 0x5f, 0x82, 0x47,
+	0xbe, 0x1c, 0xaf, 0xbc, 0xf1, 0x21, 0x4e, 0xb3, 0xfe, 0x13, 0x27, 0x3d, 0xf5, 0xcd, 0xf3, 0x9b,
+	0xdf, 0x6f, 0x66, 0x3c, 0x3b, 0x33, 0x10, 0xea, 0x4c, 0xe4, 0x59, 0x71, 0x7f, 0x5e, 0x2a, 0x69,
+	0x24, 0x0e, 0x6a, 0x33, 0xf9, 0xdf, 0x85, 0xc1, 0x4b, 0xa1, 0x35, 0x5f, 0x0a, 0x9c, 0xc0, 0xc8,
+	0x7a, 0x53, 0x99, 0xff, 0x22, 0x94, 0xce, 0x64, 0x11, 0x3b, 0xe3, 0xde, 0x24, 0x64, 0x87, 0x30,
+	0x7e, 0x0d, 0xc1, 0xba, 0x12, 0xdd, 0x6e, 0x4b, 0x11, 0xbb, 0x63, 0x77, 0x72, 0x32, 0x3b, 0x3b,
+	0x6f, 0x72, 0xbc, 0xdc, 0xf9, 0x58, 0x97, 0x88, 0x53, 0x18, 0x94, 0x7c, 0x9b, 0x4b, 0xbe, 0x88,
+	0x7b, 0x63, 0x67, 0x12, 0xcc, 0xa2, 0x56, 0xf3, 0xba, 0xc2, 0x59, 0x43, 0xc0, 0xcf, 0xc1, 0x37,
+	0xb2, 0x94, 0xb9, 0x5c, 0x6e, 0x63, 0xcf, 0x92, 0x4f, 0x5b, 0xf2, 0x6d, 0xed, 0x60, 0x2d, 0x05,
+	0x3f, 0x84, 0xe3, 0x95, 0xe0, 0xca, 0xcc, 0x05, 0x37, 0x71, 0x7f, 0xec, 0x4c, 0x42, 0xb6, 0x03,
+	0xf0, 0x19, 0xf4, 0x79, 0x2e, 0x94, 0x89, 0x8f, 0x6c, 0xa4, 0x93, 0x36, 0xd2, 0x05, 0xa1, 0xac,
+	0x72, 0x52, 0x0c, 0x93, 0xad, 0x85, 0x36, 0x7c, 0x5d, 0xc6, 0x83, 0xb1, 0x33, 0xf1, 0xd8, 0x0e,
+	0xc0, 0x67, 0x10, 0x8a, 0x74, 0x25, 0x6f, 0x5b, 0x86, 0x6f, 0x19, 0xfb, 0x20, 0xc5, 0x20, 0xe0,
+	0x4a, 0xe4, 0x7c, 0x1b, 0x1f, 0x57, 0x31, 0x5a, 0x20, 0xf9, 0xcf, 0x85, 0x41, 0xfd, 0xa7, 0x18,
+	0xc3, 0x20, 0x5d, 0xf1, 0xa2, 0x10, 0x79, 0xec, 0x8c, 0xdd, 0x49, 0xc8, 0x1a, 0x93, 0xda, 0x5b,
+	0x77, 0xa1, 0x6e, 0xaf, 0xb3, 0xd7, 0xde, 0xd7, 0x3b, 0x1f, 0xeb, 0x12, 0xf1, 0x07, 0x18, 0xa5,
+	0x72, 0x5d, 0x2a, 0xa1, 0xe9, 0x95, 0xac, 0xb6, 0x67, 0xb5, 0x71, 0xab, 0xbd, 0xdc, 0xf7, 0xb3,
+	0x43, 0x01, 0x22, 0x78, 0x0b, 0x6e, 0xb8, 0x6d, 0xf9, 0x90, 0xd9, 0x6f, 0xfc, 0x02, 0x7c, 0x12,
+	0x5f, 0x4b, 0xad, 0x6d, 0x6b, 0x83, 0x4e, 0x31, 0x04, 0x5e, 0xca, 0x4d, 0x61, 0x84, 0x62, 0x2d,
+	0x8b, 0x14, 0x25, 0x37, 0x2b, 0xab, 0x38, 0x7a, 0x4c, 0xd1, 0xb0, 0xf0, 0x23, 0x00, 0x2d, 0x37,
+	0x2a, 0x15, 0x37, 0x99, 0x11, 0xb6, 0xf9, 0x21, 0xeb, 0x20, 0xf8, 0x19, 0x9c, 0x56, 0xd6, 0xa5,
+	0x2c, 0x8c, 0xca, 0xe6, 0x1b, 0x23, 0x95, 0x7d, 0x81, 0x90, 0x3d, 0x74, 0x24, 0xdf, 0x43, 0xd0,
+	0x49, 0x83, 0x67, 0xd0, 0x9f, 0x6f, 0x8d, 0xd0, 0xb1, 0x63, 0x1f, 0xa4, 0x32, 0xf0, 0x03, 0xf0,
+	0xeb, 0xee, 0x69, 0xdb, 0x63, 0x8f, 0xb5, 0x76, 0xf2, 0xaf, 0x03, 0x7e, 0x33, 0x65, 0xf8, 0x31,
+	0x78, 0x54, 0xa7, 0xdd, 0x86, 0x60, 0x16, 0x76, 0x1e, 0xc2, 0xac, 0x98, 0x75, 0xe1, 0xb7, 0x30,
+	0xd4, 0x9b, 0xb9, 0x4e, 0x55, 0x56, 0x1a, 0x5a, 0x1c, 0xd7, 0x52, 0xdf, 0x6b, 0xa9, 0x37, 0x1d,
+	0x27, 0xdb, 0xa3, 0xe2, 0x39, 0x60, 0xd7, 0xbe, 0x11, 0x2a, 0xe3, 0xb9, 0x7d, 0xb8, 0x90, 0xbd,
+	0xc3, 0x43, 0xaf, 0xdc, 0x45, 0x2f, 0xd2, 0xfb, 0x7a, 0x3f, 0xe2, 0x77, 0x66, 0xbb, 0x48, 0xef,
+	0xd9, 0xa1, 0x20, 0xf9, 0xdb, 0x81, 0xd1, 0x01, 0x09, 0xdf, 0x87, 0x23, 0x5d, 0xe5, 0x76, 0x6c,
+	0xee, 0xda, 0xc2, 0x2f, 0xc1, 0xe7, 0x69, 0x2a, 0x4a, 0x23, 0x16, 0x8f, 0xff, 0x56, 0x4b, 0x23,
+	0x89, 0x12, 0x7f, 0x88, 0x94, 0x24, 0xbd, 0x47, 0x25, 0x0d, 0x2d, 0x99, 0x81, 0x47, 0xed, 0xa4,
+	0x2a, 0xd6, 0xc2, 0xa8, 0x2c, 0xb5, 0x4b, 0xe1, 0xb1, 0xda, 0xa2, 0xb9, 0xd4, 0x34, 0x19, 0xae,
+	0xbd, 0x48, 0xf6, 0x3b, 0xf9, 0xc7, 0x81, 0x61, 0x37, 0xdc, 0xc1, 0x10, 0x39, 0x0f, 0x86, 0xa8,
+	0xb3, 0x72, 0x55, 0x9c, 0xc6, 0xc4, 0x4f, 0xa1, 0xaf, 0x78, 0xb1, 0x14, 0x0f, 0xca, 0xbd, 0xac,
+	0x08, 0x8c, 0x9c, 0xac, 0xe2, 0xe0, 0x18, 0x02, 0x9e, 0xe7, 0xb5, 0x47, 0xdb, 0xee, 0xfb, 0xac,
+	0x0b, 0x25, 0xdf, 0xc0, 0xb0, 0x2b, 0xa4, 0x01, 0xbc, 0xcb, 0x94, 0x36, 0xf5, 0xa6, 0x57, 0x06,
+	0xfd, 0x53, 0xce, 0xb5, 0xb1, 0xf7, 0x33, 0x64, 0xf6, 0x3b, 0xf9, 0x1d, 0xfa, 0xf6, 0x26, 0xe1,
+	0x27, 0xd0, 0xcf, 0xc5, 0x9b, 0xfa, 0x38, 0x9c, 0xcc, 0x9e, 0xee, 0x9f, 0xac, 0x6b, 0x72, 0xb1,
+	0x8a, 0x41, 0xbf, 0x55, 0x5f, 0x59, 0x3b, 0xc7, 0xc7, 0xac, 0x31, 0x29, 0x43, 0x2a, 0x17, 0xa2,
+	0x9e, 0x26, 0xfb, 0x3d, 0x2d, 0x21, 0xe8, 0x1c, 0x68, 0x1c, 0x41, 0x70, 0xc5, 0x0d, 0xaf, 0xa1,
+	0xe8, 0x09, 0x3e, 0x85, 0x51, 0x33, 0xf9, 0x0d, 0xe8, 0x60, 0x04, 0x43, 0x9b, 0xb7, 0x41, 0x5c,
+	0x0c, 0xe1, 0xf8, 0xa7, 0xe6, 0xbe, 0x46, 0x3d, 0x1c, 0x82, 0x7f, 0xb3, 0xda, 0x98, 0x85, 0xfc,
+	0xb3, 0x88, 0x3c, 0xb2, 0x5e, 0x64, 0x45, 0xa6, 0x57, 0x62, 0x11, 0xf5, 0xa7, 0xcf, 0x21, 0xe8,
+	0xdc, 0x2c, 0x3c, 0x85, 0xf0, 0xd5, 0x5a, 0x2f, 0x69, 0x5f, 0x79, 0x56, 0x08, 0x15, 0x39, 0x14,
+	0xec, 0x5a, 0x2e, 0x99, 0x48, 0xa5, 0x5a, 0x44, 0x2e, 0x9e, 0x41, 0x74, 0x91, 0xa6, 0xb4, 0xbc,
+	0x59, 0xd1, 0xa0, 0xbd, 0xe9, 0x77, 0x30, 0x3a, 0x38, 0x5f, 0xe8, 0x83, 0xf7, 0x4a, 0x16, 0x54,
+	0xb5, 0x0f, 0xde, 0x8f, 0x7f, 0x65, 0x65, 0xe4, 0x60, 0x00, 0x83, 0x2b, 0x71, 0x97, 0x73, 0x43,
+	0x55, 0x0e, 0xa0, 0x77, 0xfd, 0xdb, 0x57, 0x51, 0x6f, 0xca, 0x00, 0x76, 0x8d, 0xa3, 0x12, 0x7e,
+	0x2e, 0xee, 0xa4, 0x5a, 0x73, 0x9a, 0x1b, 0x9e, 0x47, 0x4f, 0x48, 0xf6, 0x2b, 0x57, 0x45, 0x56,
+	0x2c, 0x23, 0x87, 0x0a, 0xa0, 0xb4, 0x6f, 0x84, 0xe2, 0xf3, 0x5c, 0x3c, 0x57, 0x4a, 0xaa, 0xc8,
+	0xc5, 0x13, 0x80, 0x17, 0xdc, 0xf0, 0xbc, 0xb2, 0x7b, 0x6f, 0x07, 0x00, 0x0b, 0x65, 0x69, 0xed,
+	0x55, 0x07, 0x00, 0x00,
 }